@@ -1,24 +1,44 @@
 package main
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"encoding/gob"
+	"flag"
 	"fmt"
 	"github.com/alexedwards/scs/v2"
 	"learn-golang/internal/config"
 	"learn-golang/internal/driver"
 	"learn-golang/internal/handlers"
 	"learn-golang/internal/helpers"
+	"learn-golang/internal/mailer"
 	"learn-golang/internal/models"
 	"learn-golang/internal/render"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
 const port = ":8080"
 
+var cancellationWindow = flag.Duration("cancellation-window", 48*time.Hour, "how long before arrival guests can cancel online")
+var baseURL = flag.String("base-url", "http://localhost:8080", "public URL of the site, used for links in emails")
+var address = flag.String("address", "Fort Smythe Bed and Breakfast", "address of the property, used in calendar invitations")
+var linkKey = flag.String("link-key", "", "secret used to sign manage-my-booking links")
+var linkTTL = flag.Duration("link-ttl", 30*24*time.Hour, "how long manage-my-booking links stay valid")
+var mailFrom = flag.String("mail-from", "me@here.com", "address email is sent from, also the organizer of calendar invitations")
+var mailWorkers = flag.Int("mail-workers", 2, "how many workers send the mail queued in the outbox")
+var mailMaxAttempts = flag.Int("mail-max-attempts", 8, "how many times to try sending an email before giving up on it")
+var mailTransport = flag.String("mail-transport", "smtp", "how to send email: smtp, or file to write .eml files to -mail-drop-dir")
+var mailDropDir = flag.String("mail-drop-dir", "./tmp/mail", "where the file mail transport writes .eml files")
+var smtpHost = flag.String("smtp-host", "localhost", "SMTP server host")
+var smtpPort = flag.Int("smtp-port", 1025, "SMTP server port")
+var smtpUser = flag.String("smtp-user", "", "SMTP username, if the server needs one")
+var smtpPassword = flag.String("smtp-password", "", "SMTP password")
+var smtpTLS = flag.String("smtp-tls", mailer.TLSNone, "SMTP TLS mode: none, starttls or tls")
+
 var app config.AppConfig
 var session *scs.SessionManager
 var infoLog *log.Logger
@@ -26,6 +46,8 @@ var errorLog *log.Logger
 
 // main is the main application function
 func main() {
+	flag.Parse()
+
 	db, err := run()
 	if err != nil {
 		log.Fatal(err)
@@ -34,10 +56,9 @@ func main() {
 	defer func(SQL *sql.DB) {
 		_ = SQL.Close()
 	}(db.SQL)
-	defer close(app.MailChan)
 
-	fmt.Println("Starting mail listener...")
-	listenForMail()
+	fmt.Println("Starting mail workers...")
+	listenForMail(handlers.Repo.DB)
 
 	fmt.Println(fmt.Sprintf("Starting application on port %s", port))
 
@@ -53,16 +74,46 @@ func main() {
 func run() (*driver.DB, error) {
 	// what am I going to put in the session
 	gob.Register(models.Reservation{})
+	gob.Register([]models.Reservation{})
 	gob.Register(models.User{})
 	gob.Register(models.Room{})
 	gob.Register(models.Restriction{})
 
-	mailChan := make(chan models.MailData)
-	app.MailChan = mailChan
-
 	// change this to true when in production
 	app.InProduction = false
 
+	app.CancellationWindow = *cancellationWindow
+	app.BaseURL = strings.TrimSuffix(*baseURL, "/")
+	app.Address = *address
+	app.MailFrom = *mailFrom
+	app.LinkTTL = *linkTTL
+
+	app.LinkKey = []byte(*linkKey)
+	if len(app.LinkKey) == 0 {
+		log.Println("No -link-key given, using a random one: emailed links will stop working on restart")
+		app.LinkKey = make([]byte, 32)
+		_, err := rand.Read(app.LinkKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch *mailTransport {
+	case "smtp":
+		app.Mailer = &mailer.SMTP{
+			Host:     *smtpHost,
+			Port:     *smtpPort,
+			Username: *smtpUser,
+			Password: *smtpPassword,
+			TLS:      *smtpTLS,
+			Timeout:  10 * time.Second,
+		}
+	case "file":
+		app.Mailer = &mailer.FileDrop{Dir: *mailDropDir}
+	default:
+		return nil, fmt.Errorf("unknown mail transport %q", *mailTransport)
+	}
+
 	infoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	app.InfoLog = infoLog
 
@@ -93,6 +144,15 @@ func run() (*driver.DB, error) {
 	}
 
 	app.TemplateCache = templateCache
+
+	emailCache, emailTextCache, err := render.CreateEmailTemplateCache()
+	if err != nil {
+		log.Fatal("cannot create email template cache: ", err)
+		return nil, err
+	}
+
+	app.EmailCache = emailCache
+	app.EmailTextCache = emailTextCache
 	app.UseCache = false
 
 	repo := handlers.NewRepo(&app, db)
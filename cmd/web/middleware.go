@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/justinas/nosurf"
 	"learn-golang/internal/helpers"
+	"learn-golang/internal/i18n"
 	"net/http"
 )
 
@@ -20,6 +21,24 @@ func NoSurf(next http.Handler) http.Handler {
 	return csrfHandler
 }
 
+// Locale puts the language the request is answered in, picked by i18n.Negotiate, in its context
+func Locale(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var chosen string
+			if c, err := r.Cookie(i18n.CookieName); err == nil {
+				chosen = c.Value
+			}
+
+			l := i18n.Negotiate(chosen, r.Header.Get("Accept-Language"))
+			w.Header().Set("Content-Language", l.Tag)
+			w.Header().Add("Vary", "Accept-Language, Cookie")
+
+			next.ServeHTTP(w, r.WithContext(i18n.NewContext(r.Context(), l)))
+		},
+	)
+}
+
 // SessionLoad loads and saves the session on every request
 func SessionLoad(next http.Handler) http.Handler {
 	return session.LoadAndSave(next)
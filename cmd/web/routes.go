@@ -5,6 +5,7 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"learn-golang/internal/config"
 	"learn-golang/internal/handlers"
+	"learn-golang/internal/models"
 	"net/http"
 )
 
@@ -12,37 +13,97 @@ func routes(_ *config.AppConfig) http.Handler {
 	mux := chi.NewRouter()
 
 	mux.Use(middleware.Recoverer)
-	mux.Use(NoSurf)
-	mux.Use(SessionLoad)
+	mux.Use(Locale)
 
-	mux.Get("/", handlers.Repo.Home)
-	mux.Get("/about", handlers.Repo.About)
-	mux.Get("/generals-quarters", handlers.Repo.Generals)
-	mux.Get("/majors-suite", handlers.Repo.Majors)
+	// the JSON API is used by scripts and partners rather than browsers, so it has no CSRF check or session
+	mux.Get("/api/openapi.json", handlers.OpenAPI)
+	mux.Route(
+		"/api/v1", func(mux chi.Router) {
+			mux.NotFound(handlers.APINotFound)
+			mux.MethodNotAllowed(handlers.APIMethodNotAllowed)
+			mux.With(handlers.Repo.RequireAPIKey(models.ScopeReadAvailability)).Get("/rooms", handlers.Repo.APIRooms)
+			mux.With(handlers.Repo.RequireAPIKey(models.ScopeReadAvailability)).Get("/availability", handlers.Repo.APIAvailability)
+			mux.With(handlers.Repo.RequireAPIKey(models.ScopeWriteReservations)).Post("/reservations", handlers.Repo.APIPostReservation)
+			mux.With(handlers.Repo.RequireAPIKey(models.ScopeReadReservations)).Get("/reservations/{code}", handlers.Repo.APIReservation)
+		},
+	)
 
-	mux.Get("/search-availability", handlers.Repo.Availability)
-	mux.Post("/search-availability", handlers.Repo.PostAvailability)
-	mux.Post("/search-availability-json", handlers.Repo.AvailabilityJSON)
-	mux.Get("/choose-room/{id}", handlers.Repo.ChooseRoom)
-	mux.Get("/book-room", handlers.Repo.BookRoom)
+	mux.Group(
+		func(mux chi.Router) {
+			mux.Use(NoSurf)
+			mux.Use(SessionLoad)
 
-	mux.Get("/contact", handlers.Repo.Contact)
+			mux.Get("/", handlers.Repo.Home)
+			mux.Get("/about", handlers.Repo.About)
+			mux.Handle("/generals-quarters", http.RedirectHandler("/rooms/generals-quarters", http.StatusMovedPermanently))
+			mux.Handle("/majors-suite", http.RedirectHandler("/rooms/majors-suite", http.StatusMovedPermanently))
+			mux.Get("/rooms", handlers.Repo.Rooms)
+			mux.Get("/rooms/{slug}", handlers.Repo.Room)
 
-	mux.Get("/make-reservation", handlers.Repo.Reservation)
-	mux.Post("/make-reservation", handlers.Repo.PostReservation)
-	mux.Get("/reservation-summary", handlers.Repo.ReservationSummary)
+			mux.Get("/search-availability", handlers.Repo.Availability)
+			mux.Post("/search-availability", handlers.Repo.PostAvailability)
+			mux.Post("/search-availability-json", handlers.Repo.AvailabilityJSON)
+			mux.Get("/room-calendar-json", handlers.Repo.RoomCalendarJSON)
+			mux.Get("/choose-room/{id}", handlers.Repo.ChooseRoom)
+			mux.Get("/choose-rooms", handlers.Repo.ChooseRooms)
+			mux.Get("/book-room", handlers.Repo.BookRoom)
 
-	mux.Get("/user/login", handlers.Repo.ShowLogin)
-	mux.Post("/user/login", handlers.Repo.PostShowLogin)
-	mux.Get("/user/logout", handlers.Repo.Logout)
+			mux.Get("/contact", handlers.Repo.Contact)
+			mux.Get("/language", handlers.Repo.Language)
 
-	fileServer := http.FileServer(http.Dir("./static/"))
-	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
+			mux.Get("/make-reservation", handlers.Repo.Reservation)
+			mux.Post("/make-reservation", handlers.Repo.PostReservation)
+			mux.Get("/reservation-summary", handlers.Repo.ReservationSummary)
 
-	mux.Route(
-		"/admin", func(mux chi.Router) {
-			mux.Use(Auth)
-			mux.Get("/dashboard", handlers.Repo.AdminDashboard)
+			mux.Get("/reservation-lookup", handlers.Repo.ReservationLookup)
+			mux.Post("/reservation-lookup", handlers.Repo.PostReservationLookup)
+			mux.Get("/my-reservation", handlers.Repo.MyReservation)
+			mux.Get("/my-reservation/change", handlers.Repo.ChangeReservation)
+			mux.Post("/my-reservation/change", handlers.Repo.PostChangeReservation)
+			mux.Post("/my-reservation/cancel", handlers.Repo.PostCancelReservation)
+			mux.Get("/manage/{token}", handlers.Repo.ManageReservation)
+
+			mux.Get("/user/login", handlers.Repo.ShowLogin)
+			mux.Post("/user/login", handlers.Repo.PostShowLogin)
+			mux.Get("/user/logout", handlers.Repo.Logout)
+
+			fileServer := http.FileServer(http.Dir("./static/"))
+			mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
+
+			mux.Route(
+				"/admin", func(mux chi.Router) {
+					mux.Use(Auth)
+					mux.Get("/dashboard", handlers.Repo.AdminDashboard)
+					mux.Get("/reservations-new", handlers.Repo.AdminNewReservations)
+					mux.Post("/reservations-new/processed", handlers.Repo.AdminProcessReservations)
+					mux.Get("/reservations-all", handlers.Repo.AdminAllReservations)
+					mux.Get("/reservation-calendar", handlers.Repo.AdminReservationsCalendar)
+					mux.Post("/reservation-calendar", handlers.Repo.PostAdminReservationsCalendar)
+
+					mux.Get("/reservations/{src}/{id}", handlers.Repo.AdminShowReservation)
+					mux.Post("/reservations/{src}/{id}", handlers.Repo.AdminPostShowReservation)
+					mux.Post("/reservations/{src}/{id}/delete", handlers.Repo.AdminDeleteReservation)
+					mux.Post("/reservations/{src}/{id}/processed", handlers.Repo.AdminProcessReservation)
+
+					mux.Get("/rooms", handlers.Repo.AdminRooms)
+					mux.Get("/rooms/new", handlers.Repo.AdminNewRoom)
+					mux.Post("/rooms/new", handlers.Repo.AdminPostRoom)
+					mux.Get("/rooms/{id}", handlers.Repo.AdminShowRoom)
+					mux.Post("/rooms/{id}", handlers.Repo.AdminPostRoom)
+					mux.Post("/rooms/{id}/delete", handlers.Repo.AdminDeleteRoom)
+					mux.Post("/rooms/{id}/seasons", handlers.Repo.AdminPostSeasonalRate)
+					mux.Post("/rooms/{id}/seasons/{seasonID}/delete", handlers.Repo.AdminDeleteSeasonalRate)
+					mux.Post("/rooms/{id}/rules", handlers.Repo.AdminPostBookingRules)
+
+					mux.Get("/api-keys", handlers.Repo.AdminAPIKeys)
+					mux.Post("/api-keys", handlers.Repo.AdminPostAPIKey)
+					mux.Post("/api-keys/{id}/revoke", handlers.Repo.AdminRevokeAPIKey)
+
+					mux.Get("/outbox", handlers.Repo.AdminOutbox)
+					mux.Get("/outbox/{id}", handlers.Repo.AdminShowOutboxMessage)
+					mux.Post("/outbox/{id}/resend", handlers.Repo.AdminResendOutboxMessage)
+				},
+			)
 		},
 	)
 
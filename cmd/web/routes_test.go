@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"learn-golang/internal/config"
+	"learn-golang/internal/handlers"
+	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -18,3 +21,38 @@ func TestRoutes(t *testing.T) {
 		t.Error(fmt.Sprintf("type is not *chi.Mux, type is %T", v))
 	}
 }
+
+func TestRoutes_OpenAPI(t *testing.T) {
+	var app config.AppConfig
+
+	mux := routes(&app).(*chi.Mux)
+
+	documented := make(map[string]bool)
+	for _, op := range handlers.APIOperations {
+		documented[op.Method+" "+op.Path] = true
+	}
+
+	served := make(map[string]bool)
+	err := chi.Walk(
+		mux, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			if (strings.HasPrefix(route, "/api/") || strings.Contains(route, "json")) && route != "/api/openapi.json" {
+				served[method+" "+route] = true
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for route := range served {
+		if !documented[route] {
+			t.Errorf("%s is served but missing from the OpenAPI document", route)
+		}
+	}
+	for route := range documented {
+		if !served[route] {
+			t.Errorf("%s is in the OpenAPI document but not served", route)
+		}
+	}
+}
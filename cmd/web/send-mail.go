@@ -1,56 +1,22 @@
 package main
 
 import (
-	"fmt"
-	mail "github.com/xhit/go-simple-mail/v2"
-	"learn-golang/internal/models"
-	"log"
-	"os"
-	"strings"
+	"context"
+	"learn-golang/internal/outbox"
 	"time"
 )
 
-func listenForMail() {
-	go func() {
-		for {
-			msg := <-app.MailChan
-			sendMessage(msg)
-		}
-	}()
-}
-
-func sendMessage(m models.MailData) {
-	server := mail.NewSMTPClient()
-	server.Host = "localhost"
-	server.Port = 1025
-	server.KeepAlive = false
-	server.ConnectTimeout = 10 * time.Second
-	server.SendTimeout = 10 * time.Second
-
-	client, err := server.Connect()
-	if err != nil {
-		errorLog.Println(err)
+// listenForMail starts the workers that send the mail queued in the outbox through app.Mailer
+func listenForMail(store outbox.Store) {
+	pool := &outbox.Pool{
+		Store:        store,
+		Send:         app.Mailer.Send,
+		Workers:      *mailWorkers,
+		MaxAttempts:  *mailMaxAttempts,
+		PollInterval: 5 * time.Second,
+		Lease:        5 * time.Minute,
+		ErrorLog:     errorLog,
 	}
 
-	email := mail.NewMSG()
-	email.SetFrom(m.From).AddTo(m.To).SetSubject(m.Subject)
-	if m.Template == "" {
-		email.SetBody(mail.TextHTML, m.Content)
-	} else {
-		data, err := os.ReadFile(fmt.Sprintf("./email-templates/%s", m.Template))
-		if err != nil {
-			app.ErrorLog.Println(err)
-		}
-
-		mailTemplate := string(data)
-		msgToSend := strings.Replace(mailTemplate, "[%body%]", m.Content, 1)
-		email.SetBody(mail.TextHTML, msgToSend)
-	}
-
-	err = email.Send(client)
-	if err != nil {
-		log.Println(err)
-	} else {
-		log.Println("Email sent!")
-	}
+	go pool.Run(context.Background())
 }
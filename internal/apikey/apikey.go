@@ -0,0 +1,42 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// keyPrefix starts every key, so leaked keys are easy to recognise
+const keyPrefix = "bk_"
+
+// displayLength is how many leading characters of a key are kept to tell keys apart in the admin tool
+const displayLength = 11
+
+// New returns a fresh API key, the short prefix shown in the admin tool, and the hash stored in its place
+func New() (key, prefix, hash string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:displayLength], Hash(key), nil
+}
+
+// Hash returns the hex SHA-256 of a key; keys are long and random, so a fast hash is enough to look them up
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// FromHeader returns the key sent as a bearer token in an Authorization header, or "" if there is none
+func FromHeader(header string) string {
+	scheme, key, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(key)
+}
@@ -0,0 +1,44 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	key, prefix, hash, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(key, "bk_") || !strings.HasPrefix(key, prefix) {
+		t.Errorf("expected key %s to start with bk_ and its prefix %s", key, prefix)
+	}
+	if hash != Hash(key) || strings.Contains(hash, key) {
+		t.Error("expected the stored hash to be the hash of the key")
+	}
+
+	other, _, _, _ := New()
+	if other == key {
+		t.Error("expected two keys to differ")
+	}
+}
+
+func TestFromHeader(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"Bearer bk_abc", "bk_abc"},
+		{"bearer  bk_abc ", "bk_abc"},
+		{"Basic dXNlcjpwYXNz", ""},
+		{"bk_abc", ""},
+		{"", ""},
+	}
+
+	for _, e := range tests {
+		if got := FromHeader(e.header); got != e.expected {
+			t.Errorf("FromHeader(%q): expected %q, got %q", e.header, e.expected, got)
+		}
+	}
+}
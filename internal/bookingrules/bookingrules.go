@@ -0,0 +1,56 @@
+package bookingrules
+
+import (
+	"fmt"
+	"learn-golang/internal/models"
+	"learn-golang/internal/pricing"
+	"time"
+)
+
+// Violation is the error Check returns for a stay a room does not accept. Message is an English
+// catalogue key filled in with Args, so it can be shown to a guest in their language.
+type Violation struct {
+	Message string
+	Args    []any
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf(v.Message, v.Args...)
+}
+
+// violation returns a Violation for the message key filled in with args
+func violation(message string, args ...any) *Violation {
+	return &Violation{Message: message, Args: args}
+}
+
+// Check returns a *Violation describing the first rule a stay from start to end, booked at now, breaks,
+// or nil if the room accepts it. Lead time is measured to the start of the arrival day.
+func Check(rules models.BookingRules, start, end, now time.Time) error {
+	nights := int(end.Sub(start).Hours()/24 + 0.5)
+
+	if rules.MinNights > 0 && nights < rules.MinNights {
+		return violation("Stays in this room must be at least %d nights", rules.MinNights)
+	}
+
+	if rules.MaxNights > 0 && nights > rules.MaxNights {
+		return violation("Stays in this room can be at most %d nights", rules.MaxNights)
+	}
+
+	if rules.WeekendMinNights > 0 && nights < rules.WeekendMinNights {
+		for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+			if pricing.IsWeekendNight(d) {
+				return violation("Stays in this room over a weekend must be at least %d nights", rules.WeekendMinNights)
+			}
+		}
+	}
+
+	if rules.NoArrivalOn(start.Weekday()) {
+		return violation("This room does not take arrivals on that day of the week")
+	}
+
+	if rules.LeadTimeHours > 0 && start.Sub(now) < time.Duration(rules.LeadTimeHours)*time.Hour {
+		return violation("This room must be booked at least %d hours ahead", rules.LeadTimeHours)
+	}
+
+	return nil
+}
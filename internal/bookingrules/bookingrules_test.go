@@ -0,0 +1,61 @@
+package bookingrules
+
+import (
+	"errors"
+	"learn-golang/internal/models"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	d, _ := time.Parse("2006-01-02", s)
+	return d
+}
+
+func TestCheck(t *testing.T) {
+	// booked on Monday 6 June 2050 at noon
+	now := date("2050-06-06").Add(12 * time.Hour)
+
+	tests := []struct {
+		name    string
+		rules   models.BookingRules
+		start   string
+		end     string
+		allowed bool
+	}{
+		{"no rules", models.BookingRules{}, "2050-06-07", "2050-06-08", true},
+		{"long enough", models.BookingRules{MinNights: 2}, "2050-06-07", "2050-06-09", true},
+		{"too short", models.BookingRules{MinNights: 2}, "2050-06-07", "2050-06-08", false},
+		{"too long", models.BookingRules{MaxNights: 7}, "2050-06-07", "2050-06-15", false},
+		{"short weekday stay", models.BookingRules{WeekendMinNights: 2}, "2050-06-07", "2050-06-08", true},
+		{"short weekend stay", models.BookingRules{WeekendMinNights: 2}, "2050-06-10", "2050-06-11", false},
+		{"long weekend stay", models.BookingRules{WeekendMinNights: 2}, "2050-06-10", "2050-06-12", true},
+		{"Thursday over Friday night", models.BookingRules{WeekendMinNights: 3}, "2050-06-09", "2050-06-11", false},
+		{"Sunday arrival", models.BookingRules{NoArrivalDays: 1 << time.Sunday}, "2050-06-12", "2050-06-14", false},
+		{"Monday arrival", models.BookingRules{NoArrivalDays: 1 << time.Sunday}, "2050-06-13", "2050-06-14", true},
+		{"inside lead time", models.BookingRules{LeadTimeHours: 24}, "2050-06-07", "2050-06-08", false},
+		{"outside lead time", models.BookingRules{LeadTimeHours: 24}, "2050-06-08", "2050-06-09", true},
+	}
+
+	for _, e := range tests {
+		err := Check(e.rules, date(e.start), date(e.end), now)
+		if e.allowed && err != nil {
+			t.Errorf("%s: expected the stay to be allowed, got %s", e.name, err)
+		}
+		if !e.allowed && err == nil {
+			t.Errorf("%s: expected the stay to be refused", e.name)
+		}
+		var v *Violation
+		if err != nil && !errors.As(err, &v) {
+			t.Errorf("%s: expected a *Violation, got %T", e.name, err)
+		}
+	}
+}
+
+func TestBookingRules_NoArrivalOn(t *testing.T) {
+	rules := models.BookingRules{NoArrivalDays: 1<<time.Sunday | 1<<time.Saturday}
+
+	if !rules.NoArrivalOn(time.Sunday) || !rules.NoArrivalOn(time.Saturday) || rules.NoArrivalOn(time.Monday) {
+		t.Errorf("unexpected arrival days for mask %b", rules.NoArrivalDays)
+	}
+}
@@ -3,17 +3,27 @@ package config
 import (
 	"github.com/alexedwards/scs/v2"
 	"html/template"
-	"learn-golang/internal/models"
+	"learn-golang/internal/mailer"
 	"log"
+	ttemplate "text/template"
+	"time"
 )
 
 // AppConfig holds the application config
 type AppConfig struct {
-	UseCache      bool
-	TemplateCache map[string]*template.Template
-	InfoLog       *log.Logger
-	ErrorLog      *log.Logger
-	InProduction  bool
-	Session       *scs.SessionManager
-	MailChan      chan models.MailData
+	UseCache           bool
+	TemplateCache      map[string]*template.Template
+	EmailCache         map[string]*template.Template
+	EmailTextCache     map[string]*ttemplate.Template
+	InfoLog            *log.Logger
+	ErrorLog           *log.Logger
+	InProduction       bool
+	Session            *scs.SessionManager
+	CancellationWindow time.Duration
+	BaseURL            string
+	Address            string
+	MailFrom           string
+	LinkKey            []byte
+	LinkTTL            time.Duration
+	Mailer             mailer.Mailer
 }
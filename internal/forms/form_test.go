@@ -1,10 +1,12 @@
 package forms
 
 import (
+	"learn-golang/internal/i18n"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 )
 
 func TestForm_Valid(t *testing.T) {
@@ -123,3 +125,87 @@ func TestForm_IsEmail(t *testing.T) {
 		t.Error("got an valid for invalid email address")
 	}
 }
+
+func TestForm_DateRange(t *testing.T) {
+	now := time.Date(2050, 1, 10, 15, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name          string
+		start         string
+		end           string
+		expectedField string
+	}{
+		{"valid", "2050-01-10", "2050-01-12", ""},
+		{"longest stay", "2050-01-10", "2050-03-11", ""},
+		{"missing start", "", "2050-01-12", "start"},
+		{"malformed end", "2050-01-10", "12/01/2050", "end"},
+		{"end before start", "2050-01-12", "2050-01-10", "end"},
+		{"zero nights", "2050-01-12", "2050-01-12", "end"},
+		{"in the past", "2050-01-09", "2050-01-12", "start"},
+		{"too long", "2050-01-10", "2050-03-12", "end"},
+	}
+
+	for _, e := range tests {
+		form := New(url.Values{"start": {e.start}, "end": {e.end}})
+		start, end := form.DateRange("start", "end", now)
+
+		if e.expectedField == "" {
+			if !form.Valid() {
+				t.Errorf("%s: expected valid range, got %v", e.name, form.Errors)
+			}
+			if start.Format(DateLayout) != e.start || end.Format(DateLayout) != e.end {
+				t.Errorf("%s: expected %s to %s, got %s to %s", e.name, e.start, e.end, start, end)
+			}
+			continue
+		}
+
+		if form.Errors.Get(e.expectedField) == "" {
+			t.Errorf("%s: expected an error on %s, got %v", e.name, e.expectedField, form.Errors)
+		}
+	}
+}
+
+func TestForm_Count(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected int
+		valid    bool
+	}{
+		{"number", "3", 3, true},
+		{"empty", "", 1, true},
+		{"below min", "0", 1, false},
+		{"above max", "13", 1, false},
+		{"not a number", "two", 1, false},
+	}
+
+	for _, e := range tests {
+		form := New(url.Values{"adults": {e.value}})
+		n := form.Count("adults", 1, 12)
+
+		if n != e.expected {
+			t.Errorf("%s: expected %d, got %d", e.name, e.expected, n)
+		}
+		if form.Valid() != e.valid {
+			t.Errorf("%s: expected valid to be %v", e.name, e.valid)
+		}
+	}
+}
+
+func TestForm_T(t *testing.T) {
+	form := New(url.Values{"start": {"2050-01-01"}, "end": {"2050-06-01"}})
+	form.DateRange("start", "end", time.Date(2049, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	if got := form.Errors.Get("end"); got != "Stays cannot be longer than 60 nights" {
+		t.Errorf("expected the message in English, got %q", got)
+	}
+
+	fr, _ := i18n.Lookup("fr")
+	form = New(url.Values{"start": {"2050-01-01"}, "end": {"2050-06-01"}})
+	form.T = fr.T
+	form.DateRange("start", "end", time.Date(2049, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	if got := form.Errors.Get("end"); got != "Les séjours ne peuvent pas dépasser 60 nuits" {
+		t.Errorf("expected the message translated before it is filled in, got %q", got)
+	}
+}
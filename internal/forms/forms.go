@@ -4,13 +4,35 @@ import (
 	"fmt"
 	"github.com/asaskevich/govalidator"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// DateLayout is the format of every date field
+const DateLayout = "2006-01-02"
+
+// MaxStayNights is the longest stay a date range may cover
+const MaxStayNights = 60
+
 // Form creates a custom form struct, embeds an url.Values object
 type Form struct {
 	url.Values
 	Errors errors
+	// T translates the format of an error message before its arguments are filled in, e.g. a locale's T;
+	// without it messages are in English
+	T func(format string, args ...any) string
+}
+
+// message returns the error message format filled in with args, translated by T if the form has one
+func (f *Form) message(format string, args ...any) string {
+	if f.T != nil {
+		return f.T(format, args...)
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
 }
 
 // Valid returns true if there are no errors, otherwise false
@@ -21,8 +43,8 @@ func (f *Form) Valid() bool {
 // New initializes a form struct
 func New(data url.Values) *Form {
 	return &Form{
-		data,
-		map[string][]string{},
+		Values: data,
+		Errors: map[string][]string{},
 	}
 }
 
@@ -30,7 +52,7 @@ func (f *Form) Required(fields ...string) {
 	for _, field := range fields {
 		value := f.Get(field)
 		if strings.TrimSpace(value) == "" {
-			f.Errors.Add(field, "This field cannot be blank")
+			f.Errors.Add(field, f.message("This field cannot be blank"))
 		}
 	}
 }
@@ -39,7 +61,7 @@ func (f *Form) Required(fields ...string) {
 func (f *Form) Has(field string) bool {
 	x := f.Get(field)
 	if x == "" {
-		f.Errors.Add(field, "This field cannot be blank")
+		f.Errors.Add(field, f.message("This field cannot be blank"))
 		return false
 	}
 	return true
@@ -49,7 +71,7 @@ func (f *Form) Has(field string) bool {
 func (f *Form) MinLength(field string, length int) bool {
 	x := f.Get(field)
 	if len(x) < length {
-		f.Errors.Add(field, fmt.Sprintf("This field must be at least %d characters long", length))
+		f.Errors.Add(field, f.message("This field must be at least %d characters long", length))
 		return false
 	}
 	return true
@@ -58,6 +80,54 @@ func (f *Form) MinLength(field string, length int) bool {
 // IsEmail checks for valid email address
 func (f *Form) IsEmail(field string) {
 	if !govalidator.IsEmail(f.Get(field)) {
-		f.Errors.Add(field, "Invalid email address")
+		f.Errors.Add(field, f.message("Invalid email address"))
+	}
+}
+
+// DateRange parses the arrival and departure dates in startField and endField, adding an error to the
+// field at fault when either is missing or malformed, the stay is not at least one night, it starts
+// before today, or it is longer than MaxStayNights. The dates are returned as midnight UTC.
+func (f *Form) DateRange(startField, endField string, now time.Time) (time.Time, time.Time) {
+	start, startErr := time.Parse(DateLayout, strings.TrimSpace(f.Get(startField)))
+	if startErr != nil {
+		f.Errors.Add(startField, f.message("Enter a date formatted as YYYY-MM-DD"))
+	}
+	end, endErr := time.Parse(DateLayout, strings.TrimSpace(f.Get(endField)))
+	if endErr != nil {
+		f.Errors.Add(endField, f.message("Enter a date formatted as YYYY-MM-DD"))
+	}
+	if startErr != nil || endErr != nil {
+		return start, end
 	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	nights := int(end.Sub(start).Hours() / 24)
+
+	switch {
+	case start.Before(today):
+		f.Errors.Add(startField, f.message("Arrival cannot be in the past"))
+	case nights < 1:
+		f.Errors.Add(endField, f.message("Departure must be at least one day after arrival"))
+	case nights > MaxStayNights:
+		f.Errors.Add(endField, f.message("Stays cannot be longer than %d nights", MaxStayNights))
+	}
+
+	return start, end
+}
+
+// Count parses a whole number field from min through max, reporting an error on the field otherwise;
+// an empty field counts as min
+func (f *Form) Count(field string, min, max int) int {
+	value := strings.TrimSpace(f.Get(field))
+	if value == "" {
+		return min
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		f.Errors.Add(field, f.message("Enter a number from %d to %d", min, max))
+		return min
+	}
+
+	return n
 }
@@ -0,0 +1,465 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"learn-golang/internal/apikey"
+	"learn-golang/internal/bookingrules"
+	"learn-golang/internal/forms"
+	"learn-golang/internal/helpers"
+	"learn-golang/internal/i18n"
+	"learn-golang/internal/models"
+	"learn-golang/internal/party"
+	"learn-golang/internal/repository"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiEnvelope wraps every JSON API response: data on success, error otherwise
+type apiEnvelope struct {
+	Data  any       `json:"data,omitempty"`
+	Error *apiError `json:"error,omitempty"`
+}
+
+// apiError describes why an API request failed, with per-field messages for invalid input
+type apiError struct {
+	Code    string              `json:"code"`
+	Message string              `json:"message"`
+	Fields  map[string][]string `json:"fields,omitempty"`
+}
+
+// apiPhoto is a room photo as returned by the API
+type apiPhoto struct {
+	URL     string `json:"url"`
+	Caption string `json:"caption"`
+}
+
+// apiRoom is a room as returned by the API, with prices in cents
+type apiRoom struct {
+	ID               int        `json:"id"`
+	Slug             string     `json:"slug"`
+	Name             string     `json:"name"`
+	Description      string     `json:"description"`
+	Capacity         int        `json:"capacity"`
+	NightlyRate      int        `json:"nightly_rate"`
+	WeekendSurcharge int        `json:"weekend_surcharge"`
+	Photos           []apiPhoto `json:"photos"`
+}
+
+// apiAvailability is the answer to an availability search, with the price of the stay when a room is free
+type apiAvailability struct {
+	StartDate  string  `json:"start_date"`
+	EndDate    string  `json:"end_date"`
+	Room       apiRoom `json:"room"`
+	Available  bool    `json:"available"`
+	TotalPrice int     `json:"total_price,omitempty"`
+}
+
+// apiNight is the price of one night of a reservation
+type apiNight struct {
+	Date      string `json:"date"`
+	Rate      int    `json:"rate"`
+	Surcharge int    `json:"surcharge"`
+	Season    string `json:"season,omitempty"`
+}
+
+// apiReservation is a reservation as returned by the API
+type apiReservation struct {
+	ConfirmationCode string     `json:"confirmation_code"`
+	FirstName        string     `json:"first_name"`
+	LastName         string     `json:"last_name"`
+	Email            string     `json:"email"`
+	Phone            string     `json:"phone"`
+	RoomID           int        `json:"room_id"`
+	RoomName         string     `json:"room_name"`
+	StartDate        string     `json:"start_date"`
+	EndDate          string     `json:"end_date"`
+	TotalPrice       int        `json:"total_price"`
+	Nights           []apiNight `json:"nights,omitempty"`
+	Cancelled        bool       `json:"cancelled"`
+	CancelledAt      *time.Time `json:"cancelled_at,omitempty"`
+}
+
+// apiReservationRequest is the body of a request to book a room, for one adult unless adults is given
+type apiReservationRequest struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+	RoomID    int    `json:"room_id"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	Adults    int    `json:"adults,omitempty"`
+	Children  int    `json:"children,omitempty"`
+}
+
+// newAPIRoom converts a room to its API representation
+func newAPIRoom(room models.Room) apiRoom {
+	out := apiRoom{
+		ID:               room.ID,
+		Slug:             room.Slug,
+		Name:             room.RoomName,
+		Description:      room.Description,
+		Capacity:         room.Capacity,
+		NightlyRate:      room.NightlyRate,
+		WeekendSurcharge: room.WeekendSurcharge,
+		Photos:           []apiPhoto{},
+	}
+	for _, p := range room.Photos {
+		out.Photos = append(out.Photos, apiPhoto{URL: p.Path, Caption: p.Caption})
+	}
+	return out
+}
+
+// newAPIReservation converts a reservation to its API representation
+func newAPIReservation(res models.Reservation) apiReservation {
+	out := apiReservation{
+		ConfirmationCode: res.ConfirmationCode,
+		FirstName:        res.FirstName,
+		LastName:         res.LastName,
+		Email:            res.Email,
+		Phone:            res.Phone,
+		RoomID:           res.RoomID,
+		RoomName:         res.Room.RoomName,
+		StartDate:        res.StartDate.Format(forms.DateLayout),
+		EndDate:          res.EndDate.Format(forms.DateLayout),
+		TotalPrice:       res.TotalPrice,
+		Cancelled:        res.Cancelled(),
+	}
+	if res.Cancelled() {
+		cancelledAt := res.CancelledAt
+		out.CancelledAt = &cancelledAt
+	}
+	for _, n := range res.Nights {
+		out.Nights = append(out.Nights, apiNight{
+			Date:      n.Date.Format(forms.DateLayout),
+			Rate:      n.Rate,
+			Surcharge: n.Surcharge,
+			Season:    n.Season,
+		})
+	}
+	return out
+}
+
+// writeJSON writes data in the API envelope with the given status code
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	writeEnvelope(w, status, apiEnvelope{Data: data})
+}
+
+// writeAPIError writes an error in the API envelope with the given status code
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	writeEnvelope(w, status, apiEnvelope{Error: &apiError{Code: code, Message: message}})
+}
+
+// writeAPIValidationError writes the field errors of an invalid form in the API envelope
+func writeAPIValidationError(w http.ResponseWriter, form *forms.Form) {
+	writeEnvelope(w, http.StatusUnprocessableEntity, apiEnvelope{
+		Error: &apiError{
+			Code:    "validation_failed",
+			Message: "Some fields are missing or invalid",
+			Fields:  form.Errors,
+		},
+	})
+}
+
+// writeEnvelope marshals an API envelope and writes it with the given status code
+func writeEnvelope(w http.ResponseWriter, status int, env apiEnvelope) {
+	out, err := json.Marshal(env)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(out)
+}
+
+// apiServerError logs an unexpected error and answers with a generic JSON 500
+func (rp *Repository) apiServerError(w http.ResponseWriter, err error) {
+	rp.App.ErrorLog.Println(err)
+	writeAPIError(w, http.StatusInternalServerError, "internal_error", "Something went wrong on our side")
+}
+
+// APINotFound answers requests for unknown API paths
+func APINotFound(w http.ResponseWriter, _ *http.Request) {
+	writeAPIError(w, http.StatusNotFound, "not_found", "No such endpoint")
+}
+
+// APIMethodNotAllowed answers requests using a method an API path does not support
+func APIMethodNotAllowed(w http.ResponseWriter, _ *http.Request) {
+	writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed on this endpoint")
+}
+
+// apiKeyContextKey is the request context key under which RequireAPIKey stores the caller's API key
+type apiKeyContextKey struct{}
+
+// RequireAPIKey returns middleware that only lets through requests bearing an unrevoked API key
+// with the given scope in their Authorization header, and records when each key was last used
+func (rp *Repository) RequireAPIKey(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				key := apikey.FromHeader(r.Header.Get("Authorization"))
+				if key == "" {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					writeAPIError(w, http.StatusUnauthorized, "unauthorized", "An API key is required")
+					return
+				}
+
+				k, err := rp.DB.GetAPIKeyByHash(apikey.Hash(key))
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
+					rp.apiServerError(w, err)
+					return
+				}
+				if err != nil || k.Revoked() {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					writeAPIError(w, http.StatusUnauthorized, "unauthorized", "The API key is not valid")
+					return
+				}
+
+				if !k.HasScope(scope) {
+					writeAPIError(w, http.StatusForbidden, "forbidden", fmt.Sprintf("The API key lacks the %s scope", scope))
+					return
+				}
+
+				err = rp.DB.UpdateAPIKeyLastUsed(k.ID)
+				if err != nil {
+					rp.App.ErrorLog.Println(err)
+				}
+
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, k)))
+			},
+		)
+	}
+}
+
+// apiKeyFromContext returns the API key RequireAPIKey authenticated the request with
+func apiKeyFromContext(r *http.Request) (models.APIKey, bool) {
+	k, ok := r.Context().Value(apiKeyContextKey{}).(models.APIKey)
+	return k, ok
+}
+
+// APIRooms lists the rooms that can be booked
+func (rp *Repository) APIRooms(w http.ResponseWriter, _ *http.Request) {
+	rooms, err := rp.DB.AllRooms()
+	if err != nil {
+		rp.apiServerError(w, err)
+		return
+	}
+
+	out := []apiRoom{}
+	for _, room := range rooms {
+		if !room.Retired {
+			out = append(out, newAPIRoom(room))
+		}
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+// APIAvailability reports which rooms are free between start and end, or whether room_id is, with the price of the stay
+func (rp *Repository) APIAvailability(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	form := forms.New(q)
+	startDate, endDate := form.DateRange("start", "end", time.Now())
+
+	roomID := 0
+	if q.Has("room_id") {
+		var err error
+		roomID, err = strconv.Atoi(q.Get("room_id"))
+		if err != nil {
+			form.Errors.Add("room_id", "Must be a number")
+		}
+	}
+
+	if !form.Valid() {
+		writeAPIValidationError(w, form)
+		return
+	}
+
+	var rooms []models.Room
+	if q.Has("room_id") {
+		room, err := rp.DB.GetRoomById(roomID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			rp.apiServerError(w, err)
+			return
+		}
+		if err != nil || room.ID == 0 || room.Retired {
+			writeAPIError(w, http.StatusNotFound, "room_not_found", "No such room")
+			return
+		}
+		rooms = append(rooms, room)
+	} else {
+		all, err := rp.DB.AllRooms()
+		if err != nil {
+			rp.apiServerError(w, err)
+			return
+		}
+		for _, room := range all {
+			if !room.Retired {
+				rooms = append(rooms, room)
+			}
+		}
+	}
+
+	out := []apiAvailability{}
+	for _, room := range rooms {
+		available, err := rp.DB.SearchAvailabilityByDatesByRoomID(startDate, endDate, room.ID)
+		if err != nil {
+			rp.apiServerError(w, err)
+			return
+		}
+
+		a := apiAvailability{
+			StartDate: startDate.Format(forms.DateLayout),
+			EndDate:   endDate.Format(forms.DateLayout),
+			Room:      newAPIRoom(room),
+			Available: available,
+		}
+		if available {
+			res := models.Reservation{RoomID: room.ID, Room: room, StartDate: startDate, EndDate: endDate}
+			err = rp.priceReservation(&res)
+			if err != nil {
+				rp.apiServerError(w, err)
+				return
+			}
+			a.TotalPrice = res.TotalPrice
+		}
+		out = append(out, a)
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+// APIPostReservation books a room from a JSON request and returns the new reservation
+func (rp *Repository) APIPostReservation(w http.ResponseWriter, r *http.Request) {
+	var req apiReservationRequest
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&req)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", fmt.Sprintf("Request body is not valid JSON: %s", err))
+		return
+	}
+
+	values := url.Values{}
+	values.Set("first_name", strings.TrimSpace(req.FirstName))
+	values.Set("last_name", strings.TrimSpace(req.LastName))
+	values.Set("email", strings.TrimSpace(req.Email))
+	values.Set("phone", strings.TrimSpace(req.Phone))
+	values.Set("start_date", req.StartDate)
+	values.Set("end_date", req.EndDate)
+	if req.RoomID > 0 {
+		values.Set("room_id", strconv.Itoa(req.RoomID))
+	}
+	if req.Adults != 0 {
+		values.Set("adults", strconv.Itoa(req.Adults))
+	}
+	values.Set("children", strconv.Itoa(req.Children))
+
+	form := forms.New(values)
+	form.Required("first_name", "last_name", "email", "room_id")
+	form.MinLength("first_name", 3)
+	form.IsEmail("email")
+	startDate, endDate := form.DateRange("start_date", "end_date", time.Now())
+	adults := form.Count("adults", 1, party.MaxGuests)
+	children := form.Count("children", 0, party.MaxGuests)
+
+	var room models.Room
+	if req.RoomID > 0 {
+		room, err = rp.DB.GetRoomById(req.RoomID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			rp.apiServerError(w, err)
+			return
+		}
+		if err != nil || room.ID == 0 || room.Retired {
+			form.Errors.Add("room_id", "No such room")
+		} else if adults+children > room.Capacity {
+			form.Errors.Add("children", fmt.Sprintf("The room sleeps at most %d guests", room.Capacity))
+		}
+	}
+
+	if !form.Valid() {
+		writeAPIValidationError(w, form)
+		return
+	}
+
+	reservation := models.Reservation{
+		FirstName: values.Get("first_name"),
+		LastName:  values.Get("last_name"),
+		Email:     values.Get("email"),
+		Phone:     values.Get("phone"),
+		StartDate: startDate,
+		EndDate:   endDate,
+		RoomID:    room.ID,
+		Room:      room,
+		Adults:    adults,
+		Children:  children,
+	}
+
+	err = rp.priceReservation(&reservation)
+	if err != nil {
+		rp.apiServerError(w, err)
+		return
+	}
+
+	reservation.ConfirmationCode, err = helpers.NewConfirmationCode()
+	if err != nil {
+		rp.apiServerError(w, err)
+		return
+	}
+
+	mail, err := rp.confirmationMail(i18n.FromContext(r.Context()), reservation)
+	if err != nil {
+		rp.apiServerError(w, err)
+		return
+	}
+
+	reservation.ID, err = rp.DB.InsertReservationWithRestriction(reservation, mail...)
+	if errors.Is(err, repository.ErrRoomNotAvailable) {
+		writeAPIError(w, http.StatusConflict, "room_not_available", "The room is not available for these dates")
+		return
+	}
+	var violation *bookingrules.Violation
+	if errors.As(err, &violation) {
+		form.Errors.Add("start_date", violation.Error())
+		writeAPIValidationError(w, form)
+		return
+	}
+	if err != nil {
+		rp.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/reservations/"+reservation.ConfirmationCode)
+	writeJSON(w, http.StatusCreated, newAPIReservation(reservation))
+}
+
+// APIReservation returns the reservation with the confirmation code in the URL; like the lookup
+// page it also needs the guest's email unless the API key has the admin scope, and answers 404 for any mismatch
+func (rp *Repository) APIReservation(w http.ResponseWriter, r *http.Request) {
+	res, err := rp.DB.GetReservationByCode(chi.URLParam(r, "code"))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		rp.apiServerError(w, err)
+		return
+	}
+
+	k, _ := apiKeyFromContext(r)
+	emailMatches := strings.EqualFold(res.Email, strings.TrimSpace(r.URL.Query().Get("email")))
+	if err != nil || !(emailMatches || k.HasScope(models.ScopeAdmin)) {
+		writeAPIError(w, http.StatusNotFound, "reservation_not_found", lookupNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPIReservation(res))
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var apiTests = []struct {
+	name               string
+	key                string
+	method             string
+	url                string
+	body               string
+	expectedStatusCode int
+	expectedErrorCode  string
+}{
+	{"rooms", "bk_test_read", "GET", "/api/v1/rooms", "", http.StatusOK, ""},
+	{"availability", "bk_test_read", "GET", "/api/v1/availability?start=2050-01-01&end=2050-01-03", "", http.StatusOK, ""},
+	{"availability for room", "bk_test_read", "GET", "/api/v1/availability?start=2050-01-01&end=2050-01-03&room_id=1", "", http.StatusOK, ""},
+	{"availability bad start", "bk_test_read", "GET", "/api/v1/availability?start=soon&end=2050-01-03", "", http.StatusUnprocessableEntity, "validation_failed"},
+	{"availability backwards", "bk_test_read", "GET", "/api/v1/availability?start=2050-01-03&end=2050-01-01", "", http.StatusUnprocessableEntity, "validation_failed"},
+	{"availability in the past", "bk_test_read", "GET", "/api/v1/availability?start=2020-01-01&end=2020-01-03", "", http.StatusUnprocessableEntity, "validation_failed"},
+	{"availability too long", "bk_test_read", "GET", "/api/v1/availability?start=2050-01-01&end=2051-01-01", "", http.StatusUnprocessableEntity, "validation_failed"},
+	{"availability bad room", "bk_test_read", "GET", "/api/v1/availability?start=2050-01-01&end=2050-01-03&room_id=x", "", http.StatusUnprocessableEntity, "validation_failed"},
+	{"availability unknown room", "bk_test_read", "GET", "/api/v1/availability?start=2050-01-01&end=2050-01-03&room_id=9", "", http.StatusNotFound, "room_not_found"},
+	{"availability room lookup fails", "bk_test_read", "GET", "/api/v1/availability?start=2050-01-01&end=2050-01-03&room_id=3", "", http.StatusInternalServerError, "internal_error"},
+	{
+		"book", "bk_test_write", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","room_id":1,"start_date":"2050-01-01","end_date":"2050-01-03"}`,
+		http.StatusCreated, "",
+	},
+	{
+		"book taken room", "bk_test_write", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","room_id":2,"start_date":"2050-01-01","end_date":"2050-01-03"}`,
+		http.StatusConflict, "room_not_available",
+	},
+	{
+		"book for a party", "bk_test_write", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","room_id":1,"start_date":"2050-01-01","end_date":"2050-01-03","adults":1,"children":1}`,
+		http.StatusCreated, "",
+	},
+	{
+		"book for a party too large", "bk_test_write", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","room_id":1,"start_date":"2050-01-01","end_date":"2050-01-03","adults":2,"children":1}`,
+		http.StatusUnprocessableEntity, "validation_failed",
+	},
+	{
+		"book without adults", "bk_test_write", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","room_id":1,"start_date":"2050-01-01","end_date":"2050-01-03","adults":-1}`,
+		http.StatusUnprocessableEntity, "validation_failed",
+	},
+	{
+		"book unknown room", "bk_test_write", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","room_id":9,"start_date":"2050-01-01","end_date":"2050-01-03"}`,
+		http.StatusUnprocessableEntity, "validation_failed",
+	},
+	{
+		"book when the room lookup fails", "bk_test_write", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","room_id":3,"start_date":"2050-01-01","end_date":"2050-01-03"}`,
+		http.StatusInternalServerError, "internal_error",
+	},
+	{
+		"book against booking rules", "bk_test_write", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","room_id":1,"start_date":"2053-01-01","end_date":"2053-01-03"}`,
+		http.StatusUnprocessableEntity, "validation_failed",
+	},
+	{
+		"book invalid", "bk_test_write", "POST", "/api/v1/reservations",
+		`{"first_name":"J","email":"john","room_id":1,"start_date":"2050-01-03","end_date":"2050-01-01"}`,
+		http.StatusUnprocessableEntity, "validation_failed",
+	},
+	{"book malformed", "bk_test_write", "POST", "/api/v1/reservations", `{"first_name":`, http.StatusBadRequest, "invalid_json"},
+	{"book unknown field", "bk_test_write", "POST", "/api/v1/reservations", `{"guests":3}`, http.StatusBadRequest, "invalid_json"},
+	{"reservation", "bk_test_write", "GET", "/api/v1/reservations/ABCD2345?email=john@smith.com", "", http.StatusOK, ""},
+	{"reservation wrong email", "bk_test_write", "GET", "/api/v1/reservations/ABCD2345?email=jane@smith.com", "", http.StatusNotFound, "reservation_not_found"},
+	{"reservation unknown", "bk_test_write", "GET", "/api/v1/reservations/ZZZZ2345?email=john@smith.com", "", http.StatusNotFound, "reservation_not_found"},
+	{"reservation as admin", "bk_test_admin", "GET", "/api/v1/reservations/ABCD2345", "", http.StatusOK, ""},
+	{"no key", "", "GET", "/api/v1/rooms", "", http.StatusUnauthorized, "unauthorized"},
+	{"unknown key", "bk_nope", "GET", "/api/v1/rooms", "", http.StatusUnauthorized, "unauthorized"},
+	{"revoked key", "bk_test_revoked", "GET", "/api/v1/rooms", "", http.StatusUnauthorized, "unauthorized"},
+	{"read key cannot book", "bk_test_read", "POST", "/api/v1/reservations", "{}", http.StatusForbidden, "forbidden"},
+	{"lookup key can read reservations", "bk_test_lookup", "GET", "/api/v1/reservations/ABCD2345?email=john@smith.com", "", http.StatusOK, ""},
+	{"lookup key cannot book", "bk_test_lookup", "POST", "/api/v1/reservations", "{}", http.StatusForbidden, "forbidden"},
+	{"read key cannot read reservations", "bk_test_read", "GET", "/api/v1/reservations/ABCD2345?email=john@smith.com", "", http.StatusForbidden, "forbidden"},
+	{"admin key can do anything", "bk_test_admin", "GET", "/api/v1/availability?start=2050-01-01&end=2050-01-03", "", http.StatusOK, ""},
+	{"unknown endpoint", "bk_test_read", "GET", "/api/v1/guests", "", http.StatusNotFound, "not_found"},
+	{"wrong method", "bk_test_write", "DELETE", "/api/v1/rooms", "", http.StatusMethodNotAllowed, "method_not_allowed"},
+}
+
+func TestAPI(t *testing.T) {
+	routes := getRoutes()
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	for _, e := range apiTests {
+		req, _ := http.NewRequest(e.method, ts.URL+e.url, strings.NewReader(e.body))
+		if e.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if e.key != "" {
+			req.Header.Set("Authorization", "Bearer "+e.key)
+		}
+
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != e.expectedStatusCode {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expectedStatusCode, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("for %s, expected JSON but got %s", e.name, ct)
+		}
+
+		var env struct {
+			Data  json.RawMessage `json:"data"`
+			Error *apiError       `json:"error"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&env)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Errorf("for %s, cannot decode envelope: %s", e.name, err)
+			continue
+		}
+
+		if e.expectedErrorCode == "" {
+			if env.Error != nil || len(env.Data) == 0 {
+				t.Errorf("for %s, expected data but got error %+v", e.name, env.Error)
+			}
+		} else if env.Error == nil || env.Error.Code != e.expectedErrorCode {
+			t.Errorf("for %s, expected error %s but got %+v", e.name, e.expectedErrorCode, env.Error)
+		}
+	}
+}
+
+func TestAPIPostReservation_Response(t *testing.T) {
+	body := `{"first_name":"John","last_name":"Smith","email":"john@smith.com","room_id":1,` +
+		`"start_date":"2050-01-01","end_date":"2050-01-03"}`
+	req, _ := http.NewRequest("POST", "/api/v1/reservations", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.APIPostReservation)
+	handler.ServeHTTP(rr, req)
+
+	var env struct {
+		Data apiReservation `json:"data"`
+	}
+	err := json.NewDecoder(rr.Body).Decode(&env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if env.Data.ConfirmationCode == "" {
+		t.Error("expected the new reservation to have a confirmation code")
+	}
+	if rr.Header().Get("Location") != "/api/v1/reservations/"+env.Data.ConfirmationCode {
+		t.Errorf("unexpected Location %s", rr.Header().Get("Location"))
+	}
+	// New Year's Day 2050 is a Saturday, so it carries the weekend surcharge on top of the seasonal rate
+	expected := 20000 + 1000 + 8900
+	if env.Data.TotalPrice != expected || len(env.Data.Nights) != 2 {
+		t.Errorf("expected two nights totalling %d, got %d over %d nights", expected, env.Data.TotalPrice, len(env.Data.Nights))
+	}
+}
+
+func TestAPIRequest_Fields(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/api/v1/reservations", strings.NewReader(`{"first_name":"J","room_id":9}`))
+	rr := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.APIPostReservation)
+	handler.ServeHTTP(rr, req)
+
+	var env apiEnvelope
+	err := json.NewDecoder(rr.Body).Decode(&env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Error == nil {
+		t.Fatal("expected an error")
+	}
+	for _, field := range []string{"first_name", "last_name", "email", "room_id", "start_date", "end_date"} {
+		if len(env.Error.Fields[field]) == 0 {
+			t.Errorf("expected an error for %s", field)
+		}
+	}
+}
+
+func TestAPIPostReservation_BookingRules(t *testing.T) {
+	routes := getRoutes()
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	body := `{"first_name":"John","last_name":"Smith","email":"john@smith.com","room_id":1,` +
+		`"start_date":"2053-01-01","end_date":"2053-01-03"}`
+	req, _ := http.NewRequest("POST", ts.URL+"/api/v1/reservations", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer bk_test_write")
+
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var env struct {
+		Error *apiError `json:"error"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Error == nil || len(env.Error.Fields["start_date"]) != 1 {
+		t.Errorf("expected the booking rule broken reported on start_date, got %+v", env.Error)
+	}
+}
@@ -1,21 +1,31 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
+	"learn-golang/internal/apikey"
+	"learn-golang/internal/bookingrules"
 	"learn-golang/internal/config"
 	"learn-golang/internal/driver"
 	"learn-golang/internal/forms"
 	"learn-golang/internal/helpers"
+	"learn-golang/internal/i18n"
+	"learn-golang/internal/ical"
+	"learn-golang/internal/magiclink"
 	"learn-golang/internal/models"
+	"learn-golang/internal/party"
+	"learn-golang/internal/pricing"
 	"learn-golang/internal/render"
 	"learn-golang/internal/repository"
 	"learn-golang/internal/repository/dbrepo"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -63,24 +73,28 @@ func (rp *Repository) About(w http.ResponseWriter, r *http.Request) {
 func (rp *Repository) Reservation(w http.ResponseWriter, r *http.Request) {
 	res, ok := rp.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 	if !ok {
-		rp.App.Session.Put(r.Context(), "error", "can't get reservation from session")
+		rp.App.Session.Put(r.Context(), "error", t(r, "can't get reservation from session"))
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	room, err := rp.DB.GetRoomById(res.RoomID)
+	stays, err := rp.stayReservations(r, res)
+	if errors.Is(err, errPartyTooLarge) {
+		rp.App.Session.Put(r.Context(), "error", t(r, "The rooms you chose cannot sleep your whole party"))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
 	if err != nil {
-		rp.App.Session.Put(r.Context(), "error", "can't find room")
+		rp.App.Session.Put(r.Context(), "error", t(r, "can't find room"))
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	res.Room = room
-
+	res = stays[0]
 	rp.App.Session.Put(r.Context(), "reservation", res)
 
-	sd := res.StartDate.Format("2006-01-02")
-	ed := res.EndDate.Format("2006-01-02")
+	sd := res.StartDate.Format(forms.DateLayout)
+	ed := res.EndDate.Format(forms.DateLayout)
 
 	stringMap := make(map[string]string)
 	stringMap["start_date"] = sd
@@ -88,6 +102,8 @@ func (rp *Repository) Reservation(w http.ResponseWriter, r *http.Request) {
 
 	data := make(map[string]any)
 	data["reservation"] = res
+	data["reservations"] = stays
+	data["total"] = totalPrice(stays)
 
 	_ = render.Template(
 		w, r, "make-reservation.page.tmpl", &models.TemplateData{
@@ -98,7 +114,7 @@ func (rp *Repository) Reservation(w http.ResponseWriter, r *http.Request) {
 	)
 }
 
-// PostReservation handles the posting of a reservation form
+// PostReservation handles the posting of a reservation form, booking every room of the stay together
 func (rp *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 	reservation, ok := rp.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 	if !ok {
@@ -117,7 +133,19 @@ func (rp *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 	reservation.Phone = r.Form.Get("phone")
 	reservation.Email = r.Form.Get("email")
 
-	form := forms.New(r.PostForm)
+	// price again in case rates changed since the form was shown
+	stays, err := rp.stayReservations(r, reservation)
+	if errors.Is(err, errPartyTooLarge) {
+		rp.App.Session.Put(r.Context(), "error", t(r, "The rooms you chose cannot sleep your whole party"))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := newForm(r, r.PostForm)
 
 	form.Required("first_name", "last_name", "email")
 	form.MinLength("first_name", 3)
@@ -125,105 +153,315 @@ func (rp *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 
 	if !form.Valid() {
 		data := make(map[string]any)
-		data["reservation"] = reservation
+		data["reservation"] = stays[0]
+		data["reservations"] = stays
+		data["total"] = totalPrice(stays)
 
 		_ = render.Template(
 			w, r, "make-reservation.page.tmpl", &models.TemplateData{
 				Form: form,
 				Data: data,
+				StringMap: map[string]string{
+					"start_date": reservation.StartDate.Format(forms.DateLayout),
+					"end_date":   reservation.EndDate.Format(forms.DateLayout),
+				},
 			},
 		)
 		return
 	}
 
-	newReservationID, err := rp.DB.InsertReservation(reservation)
-	if err != nil {
-		helpers.ServerError(w, err)
+	for i := range stays {
+		stays[i].ConfirmationCode, err = helpers.NewConfirmationCode()
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
 	}
 
-	restriction := models.RoomRestriction{
-		StartDate:     reservation.StartDate,
-		EndDate:       reservation.EndDate,
-		RoomID:        reservation.RoomID,
-		ReservationID: newReservationID,
-		RestrictionID: 1,
+	// the mail is queued with the reservations, so it goes out if and only if they are booked
+	var mail []models.MailData
+	for _, stay := range stays {
+		msgs, err := rp.confirmationMail(i18n.FromContext(r.Context()), stay)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		mail = append(mail, msgs...)
 	}
 
-	err = rp.DB.InsertRoomRestriction(restriction)
+	var ids []int
+	if len(stays) == 1 {
+		var id int
+		id, err = rp.DB.InsertReservationWithRestriction(stays[0], mail...)
+		ids = []int{id}
+	} else {
+		ids, err = rp.DB.InsertReservationsWithRestrictions(stays, mail...)
+	}
+	if errors.Is(err, repository.ErrRoomNotAvailable) {
+		msg := t(r, "Sorry, this room is no longer available for your dates")
+		if len(stays) > 1 {
+			msg = t(r, "Sorry, one of these rooms is no longer available for your dates")
+		}
+		rp.App.Session.Put(r.Context(), "error", msg)
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+	var violation *bookingrules.Violation
+	if errors.As(err, &violation) {
+		rp.App.Session.Put(r.Context(), "error", t(r, violation.Message, violation.Args...))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
-	// send notification
-	htmlMessage := fmt.Sprintf(
-		`
-	<strong>Reservation Confirmation</strong><br>
-    Dear %s, <br>
-    This is confirm your reservation from %s to %s.
-`, reservation.FirstName, reservation.StartDate.Format("2006-01-02"), reservation.EndDate.Format("2006-01-02"),
-	)
+	for i := range stays {
+		stays[i].ID = ids[i]
+	}
 
-	msg := models.MailData{
-		To:      reservation.Email,
-		From:    "me@here.com",
-		Subject: "Reservation Confirmation",
-		Content: htmlMessage,
+	rp.App.Session.Remove(r.Context(), "room_ids")
+	rp.App.Session.Put(r.Context(), "reservation", stays[0])
+	if len(stays) > 1 {
+		rp.App.Session.Put(r.Context(), "reservations", stays)
 	}
 
-	rp.App.MailChan <- msg
+	http.Redirect(w, r, "/reservation-summary", http.StatusSeeOther)
+}
+
+// errPartyTooLarge is returned by stayReservations when the rooms chosen cannot sleep the whole party
+var errPartyTooLarge = errors.New("the rooms chosen cannot sleep the whole party")
 
-	// send notification to proper owner
-	htmlMessage = fmt.Sprintf(
-		`
-	<strong>Reservation Notification</strong><br>
-    A reservation has been made for %s from %s to %s.
-`, reservation.Room.RoomName, reservation.StartDate.Format("2006-01-02"), reservation.EndDate.Format("2006-01-02"),
-	)
+// stayReservations returns one priced reservation per room of the stay being booked: the room of res, or
+// every room in the session's room_ids when several rooms were chosen together, sharing the party out
+// over them. It returns errPartyTooLarge if the rooms cannot sleep the party searched for.
+func (rp *Repository) stayReservations(r *http.Request, res models.Reservation) ([]models.Reservation, error) {
+	roomIDs, ok := rp.App.Session.Get(r.Context(), "room_ids").([]int)
+	if !ok || len(roomIDs) == 0 {
+		roomIDs = []int{res.RoomID}
+	}
 
-	msg = models.MailData{
-		To:       "me@here.com",
-		From:     "me@here.com",
-		Subject:  "Reservation Notification",
-		Content:  htmlMessage,
-		Template: "basic.html",
+	rooms, err := rp.roomsByID(roomIDs)
+	if err != nil {
+		return nil, err
+	}
+	// a room booked straight from its page has no party yet
+	if res.Adults > 0 && !party.Fits(rooms, res.Adults, res.Children) {
+		return nil, errPartyTooLarge
 	}
 
-	rp.App.MailChan <- msg
+	adults, children := []int{res.Adults}, []int{res.Children}
+	if len(rooms) > 1 {
+		adults, children = party.Split(rooms, res.Adults, res.Children)
+	}
 
-	rp.App.Session.Put(r.Context(), "reservation", reservation)
+	var stays []models.Reservation
+	for i, room := range rooms {
+		stay := res
+		stay.RoomID = room.ID
+		stay.Room = room
+		stay.Adults = adults[i]
+		stay.Children = children[i]
+
+		err := rp.priceReservation(&stay)
+		if err != nil {
+			return nil, err
+		}
+		stays = append(stays, stay)
+	}
 
-	http.Redirect(w, r, "/reservation-summary", http.StatusSeeOther)
+	return stays, nil
 }
 
-// Generals renders the room page
-func (rp *Repository) Generals(w http.ResponseWriter, r *http.Request) {
-	_ = render.Template(w, r, "generals.page.tmpl", &models.TemplateData{})
+// roomsByID looks up the rooms with the given IDs, in order
+func (rp *Repository) roomsByID(ids []int) ([]models.Room, error) {
+	var rooms []models.Room
+	for _, id := range ids {
+		room, err := rp.DB.GetRoomById(id)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, nil
 }
 
-// Majors renders the room page
-func (rp *Repository) Majors(w http.ResponseWriter, r *http.Request) {
-	_ = render.Template(w, r, "majors.page.tmpl", &models.TemplateData{})
+// totalPrice adds up the prices of the reservations of a stay
+func totalPrice(stays []models.Reservation) int {
+	total := 0
+	for _, stay := range stays {
+		total += stay.TotalPrice
+	}
+	return total
 }
 
-// Availability renders the search availability page
-func (rp *Repository) Availability(w http.ResponseWriter, r *http.Request) {
-	_ = render.Template(w, r, "search-availability.page.tmpl", &models.TemplateData{})
+// confirmationMail returns the mail confirming a new reservation to the guest and letting the owner know about it
+func (rp *Repository) confirmationMail(l *i18n.Locale, reservation models.Reservation) ([]models.MailData, error) {
+	ed := &models.EmailData{
+		Reservation: reservation,
+		Room:        reservation.Room,
+		TotalPrice:  reservation.TotalPrice,
+		ManageLink:  rp.manageLink(reservation),
+	}
+
+	mail, err := rp.reservationMail(l, reservation.Email, "confirmation", "notification", ed)
+	if err != nil {
+		return nil, err
+	}
+
+	// the guest's copy carries the stay as a calendar invitation
+	mail[0].Attachments = []models.Attachment{rp.calendarInvitation(l, ical.MethodRequest, reservation, ed.ManageLink)}
+
+	return mail, nil
 }
 
-// PostAvailability renders the search availability page
-func (rp *Repository) PostAvailability(w http.ResponseWriter, r *http.Request) {
-	start := r.Form.Get("start")
-	end := r.Form.Get("end")
+// Check-in and check-out times of every stay, in the property's local time
+const (
+	checkInHour  = 15
+	checkOutHour = 11
+)
+
+// calendarInvitation returns the .ics attachment that adds a reservation to the guest's calendar, or with
+// ical.MethodCancel removes it again
+func (rp *Repository) calendarInvitation(
+	l *i18n.Locale, method string, res models.Reservation, manageLink string,
+) models.Attachment {
+	host := "localhost"
+	if u, err := url.Parse(rp.App.BaseURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	description := []string{
+		l.T("Confirmation code: %s", res.ConfirmationCode),
+		l.T("Check in from %02d:00, check out by %02d:00", checkInHour, checkOutHour),
+	}
+	if manageLink != "" {
+		description = append(description, l.T("View, change or cancel your reservation")+": "+manageLink)
+	}
+
+	e := ical.Event{
+		UID:         fmt.Sprintf("reservation-%s@%s", res.ConfirmationCode, host),
+		Start:       res.StartDate,
+		End:         res.EndDate,
+		Summary:     l.T("%s at Fort Smythe", res.Room.RoomName),
+		Location:    rp.App.Address,
+		Description: strings.Join(description, "\n"),
+		URL:         manageLink,
+		Organizer:   rp.App.MailFrom,
+		Attendee:    res.Email,
+	}
+
+	name := "reservation.ics"
+	if method == ical.MethodCancel {
+		// the cancellation has to outrank the invitation it replaces
+		e.Sequence = 1
+		name = "cancellation.ics"
+	}
+
+	return models.Attachment{
+		Name:        name,
+		ContentType: ical.ContentType(method),
+		Data:        ical.Calendar(method, e, time.Now()),
+	}
+}
+
+// priceReservation fills in the nightly price breakdown and total of a reservation for its room and dates
+func (rp *Repository) priceReservation(res *models.Reservation) error {
+	seasons, err := rp.DB.GetSeasonalRatesForRoom(res.RoomID)
+	if err != nil {
+		return err
+	}
 
-	layout := "2006-01-02"
-	startDate, err := time.Parse(layout, start)
+	res.Nights, res.TotalPrice = pricing.Quote(res.Room, seasons, res.StartDate, res.EndDate)
+	return nil
+}
+
+// Rooms renders the list of rooms
+func (rp *Repository) Rooms(w http.ResponseWriter, r *http.Request) {
+	rooms, err := rp.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	var available []models.Room
+	for _, room := range rooms {
+		if !room.Retired {
+			available = append(available, room)
+		}
+	}
+
+	data := make(map[string]any)
+	data["rooms"] = available
+
+	_ = render.Template(
+		w, r, "rooms.page.tmpl", &models.TemplateData{
+			Data: data,
+		},
+	)
+}
+
+// Room renders the page of the room named by the slug in the URL
+func (rp *Repository) Room(w http.ResponseWriter, r *http.Request) {
+	room, err := rp.DB.GetRoomBySlug(chi.URLParam(r, "slug"))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && room.Retired) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		helpers.ServerError(w, err)
+		return
 	}
-	endDate, err := time.Parse(layout, end)
+
+	data := make(map[string]any)
+	data["room"] = room
+
+	_ = render.Template(
+		w, r, "room.page.tmpl", &models.TemplateData{
+			Data: data,
+		},
+	)
+}
+
+// Availability renders the search availability page
+func (rp *Repository) Availability(w http.ResponseWriter, r *http.Request) {
+	_ = render.Template(w, r, "search-availability.page.tmpl", &models.TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+// PostAvailability renders the list of rooms free for the posted dates
+func (rp *Repository) PostAvailability(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
 	if err != nil {
 		helpers.ServerError(w, err)
+		return
+	}
+
+	form := newForm(r, r.PostForm)
+	startDate, endDate := form.DateRange("start", "end", time.Now())
+	adults := form.Count("adults", 1, party.MaxGuests)
+	children := form.Count("children", 0, party.MaxGuests)
+	if adults+children > party.MaxGuests {
+		form.Errors.Add("children", t(r, "We can take parties of up to %d guests", party.MaxGuests))
+	}
+
+	if !form.Valid() {
+		stringMap := make(map[string]string)
+		stringMap["start"] = r.Form.Get("start")
+		stringMap["end"] = r.Form.Get("end")
+		stringMap["adults"] = r.Form.Get("adults")
+		stringMap["children"] = r.Form.Get("children")
+
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = render.Template(
+			w, r, "search-availability.page.tmpl", &models.TemplateData{
+				Form:      form,
+				StringMap: stringMap,
+			},
+		)
+		return
 	}
 
 	rooms, err := rp.DB.SearchAvailabilityForAllRooms(startDate, endDate)
@@ -232,22 +470,26 @@ func (rp *Repository) PostAvailability(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(rooms) == 0 {
+	options := party.Options(rooms, adults, children)
+	if len(options) == 0 {
 		// no availability
-		rp.App.Session.Put(r.Context(), "error", "No availability")
+		rp.App.Session.Put(r.Context(), "error", t(r, "No availability for your party on these dates"))
 		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
 		return
 	}
 
 	data := make(map[string]any)
-	data["rooms"] = rooms
+	data["options"] = options
 
 	res := models.Reservation{
 		StartDate: startDate,
 		EndDate:   endDate,
+		Adults:    adults,
+		Children:  children,
 	}
 
 	rp.App.Session.Put(r.Context(), "reservation", res)
+	rp.App.Session.Remove(r.Context(), "room_ids")
 
 	_ = render.Template(
 		w, r, "choose-room.page.tmpl", &models.TemplateData{
@@ -257,31 +499,121 @@ func (rp *Repository) PostAvailability(w http.ResponseWriter, r *http.Request) {
 }
 
 type jsonResponse struct {
-	Ok        bool   `json:"ok"`
-	Message   string `json:"message"`
-	RoomID    string `json:"room_id"`
-	StartDate string `json:"start_date"`
-	EndDate   string `json:"end_date"`
+	Ok        bool                `json:"ok"`
+	Message   string              `json:"message"`
+	RoomID    string              `json:"room_id"`
+	StartDate string              `json:"start_date"`
+	EndDate   string              `json:"end_date"`
+	Errors    map[string][]string `json:"errors,omitempty"`
 }
 
 // AvailabilityJSON handles request for availability and send JSON response
 func (rp *Repository) AvailabilityJSON(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
 	sd := r.Form.Get("start")
 	ed := r.Form.Get("end")
 
-	layout := "2006-01-02"
-	startDate, _ := time.Parse(layout, sd)
-	endDate, _ := time.Parse(layout, ed)
+	form := newForm(r, r.PostForm)
+	startDate, endDate := form.DateRange("start", "end", time.Now())
 
-	roomID, _ := strconv.Atoi(r.Form.Get("room_id"))
+	roomID, err := strconv.Atoi(r.Form.Get("room_id"))
+	if err != nil {
+		form.Errors.Add("room_id", t(r, "Choose a room"))
+	}
 
-	available, _ := rp.DB.SearchAvailabilityByDatesByRoomID(startDate, endDate, roomID)
 	resp := jsonResponse{
-		Ok:        available,
-		Message:   "",
 		StartDate: sd,
 		EndDate:   ed,
-		RoomID:    strconv.Itoa(roomID),
+		RoomID:    r.Form.Get("room_id"),
+	}
+	status := http.StatusOK
+
+	if form.Valid() {
+		resp.Ok, err = rp.DB.SearchAvailabilityByDatesByRoomID(startDate, endDate, roomID)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	} else {
+		resp.Message = t(r, "Please check the dates")
+		resp.Errors = form.Errors
+		status = http.StatusUnprocessableEntity
+	}
+
+	out, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(out)
+}
+
+// nightAvailability is one night of a room's availability calendar
+type nightAvailability struct {
+	Date      string `json:"date"`
+	Available bool   `json:"available"`
+}
+
+// calendarResponse is a room's availability for every night of a month
+type calendarResponse struct {
+	Ok      bool                `json:"ok"`
+	Message string              `json:"message"`
+	RoomID  string              `json:"room_id"`
+	Month   string              `json:"month"`
+	Days    []nightAvailability `json:"days"`
+	Errors  map[string][]string `json:"errors,omitempty"`
+}
+
+// RoomCalendarJSON sends whether a room is free for each night of a month, for the datepicker to grey out
+// nights that are taken
+func (rp *Repository) RoomCalendarJSON(w http.ResponseWriter, r *http.Request) {
+	form := newForm(r, r.URL.Query())
+
+	roomID, err := strconv.Atoi(form.Get("room_id"))
+	if err != nil {
+		form.Errors.Add("room_id", t(r, "Choose a room"))
+	}
+
+	month, err := time.Parse("2006-01", form.Get("month"))
+	if err != nil {
+		form.Errors.Add("month", t(r, "Enter a month formatted as YYYY-MM"))
+	}
+
+	resp := calendarResponse{
+		RoomID: form.Get("room_id"),
+		Month:  form.Get("month"),
+		Days:   []nightAvailability{},
+	}
+	status := http.StatusOK
+
+	if form.Valid() {
+		days, err := rp.DB.GetAvailabilityForRoomByDate(roomID, month, month.AddDate(0, 1, 0))
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		if len(days) == 0 {
+			resp.Message = t(r, "Room not found")
+			status = http.StatusNotFound
+		} else {
+			resp.Ok = true
+			for _, day := range days {
+				resp.Days = append(resp.Days, nightAvailability{Date: day.Date.Format(forms.DateLayout), Available: day.Available})
+			}
+		}
+	} else {
+		resp.Message = t(r, "Please check the room and month")
+		resp.Errors = form.Errors
+		status = http.StatusUnprocessableEntity
 	}
 
 	out, err := json.MarshalIndent(resp, "", "  ")
@@ -291,6 +623,7 @@ func (rp *Repository) AvailabilityJSON(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	_, _ = w.Write(out)
 }
 
@@ -299,125 +632,600 @@ func (rp *Repository) Contact(w http.ResponseWriter, r *http.Request) {
 	_ = render.Template(w, r, "contact.page.tmpl", &models.TemplateData{})
 }
 
+// Language keeps the language the guest picked in a cookie and sends them back to the page they were on
+func (rp *Repository) Language(w http.ResponseWriter, r *http.Request) {
+	if l, ok := i18n.Lookup(r.URL.Query().Get("lang")); ok {
+		http.SetCookie(
+			w, &http.Cookie{
+				Name:     i18n.CookieName,
+				Value:    l.Tag,
+				Path:     "/",
+				MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+				HttpOnly: true,
+				Secure:   rp.App.InProduction,
+				SameSite: http.SameSiteLaxMode,
+			},
+		)
+	}
+
+	// only go back to a page on this site, never to one a crafted link names
+	to := r.URL.Query().Get("return")
+	if !strings.HasPrefix(to, "/") || strings.HasPrefix(to, "//") || strings.HasPrefix(to, "/\\") {
+		to = "/"
+	}
+
+	http.Redirect(w, r, to, http.StatusSeeOther)
+}
+
+// t translates the message key into the language of the request
+func t(r *http.Request, key string, args ...any) string {
+	return i18n.FromContext(r.Context()).T(key, args...)
+}
+
+// newForm returns a form over data whose error messages are in the language of the request
+func newForm(r *http.Request, data url.Values) *forms.Form {
+	form := forms.New(data)
+	form.T = i18n.FromContext(r.Context()).T
+	return form
+}
+
 // ReservationSummary displays the reservation summary page
 func (rp *Repository) ReservationSummary(w http.ResponseWriter, r *http.Request) {
 	reservation, ok := rp.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 	if !ok {
 		rp.App.ErrorLog.Println("Can't get error from session")
-		rp.App.Session.Put(r.Context(), "error", "Can't get reservation from session")
+		rp.App.Session.Put(r.Context(), "error", t(r, "Can't get reservation from session"))
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
 
 	rp.App.Session.Remove(r.Context(), "reservation")
 
+	stays, ok := rp.App.Session.Pop(r.Context(), "reservations").([]models.Reservation)
+	if !ok {
+		stays = []models.Reservation{reservation}
+	}
+
 	data := make(map[string]any)
 	data["reservation"] = reservation
-
-	sd := reservation.StartDate.Format("2006-01-02")
-	ed := reservation.StartDate.Format("2006-01-02")
-	stringMap := make(map[string]string)
-	stringMap["start_date"] = sd
-	stringMap["end_date"] = ed
+	data["reservations"] = stays
+	data["total"] = totalPrice(stays)
 
 	_ = render.Template(
 		w, r, "reservation-summary.page.tmpl", &models.TemplateData{
-			Data:      data,
-			StringMap: stringMap,
+			Data: data,
 		},
 	)
 }
 
-// ChooseRoom displays list of available rooms
-func (rp *Repository) ChooseRoom(w http.ResponseWriter, r *http.Request) {
-	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
+// lookupNotFound is shown for any failed lookup, so the form doesn't reveal which codes exist
+const lookupNotFound = "We couldn't find a reservation with that confirmation code and email"
+
+// ReservationLookup renders the form where a guest finds their reservation by confirmation code and email
+func (rp *Repository) ReservationLookup(w http.ResponseWriter, r *http.Request) {
+	_ = render.Template(
+		w, r, "reservation-lookup.page.tmpl", &models.TemplateData{
+			Form: forms.New(nil),
+		},
+	)
+}
 
+// PostReservationLookup finds a reservation by confirmation code and email and takes the guest to it
+func (rp *Repository) PostReservationLookup(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
-	rp.App.Session.Get(r.Context(), "reservation")
+	code := strings.ToUpper(strings.TrimSpace(r.Form.Get("confirmation_code")))
+	email := strings.TrimSpace(r.Form.Get("email"))
 
-	res, ok := rp.App.Session.Get(r.Context(), "reservation").(models.Reservation)
-	if !ok {
-		helpers.ServerError(w, err)
-		return
+	form := newForm(r, r.PostForm)
+	form.Required("confirmation_code", "email")
+	form.IsEmail("email")
+
+	if form.Valid() {
+		res, err := rp.DB.GetReservationByCode(code)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			helpers.ServerError(w, err)
+			return
+		}
+		if err != nil || !strings.EqualFold(res.Email, email) {
+			form.Errors.Add("confirmation_code", t(r, lookupNotFound))
+		}
 	}
 
-	res.RoomID = roomID
+	if !form.Valid() {
+		stringMap := make(map[string]string)
+		stringMap["confirmation_code"] = code
+		stringMap["email"] = email
 
-	rp.App.Session.Put(r.Context(), "reservation", res)
+		_ = render.Template(
+			w, r, "reservation-lookup.page.tmpl", &models.TemplateData{
+				Form:      form,
+				StringMap: stringMap,
+			},
+		)
+		return
+	}
 
-	http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
+	_ = rp.App.Session.RenewToken(r.Context())
+	rp.App.Session.Put(r.Context(), "manage_reservation_code", code)
+
+	http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
 }
 
-// BookRoom takes URL parameters, builds a session variable, and takes user to make res screen
-func (rp *Repository) BookRoom(w http.ResponseWriter, r *http.Request) {
-	roomID, _ := strconv.Atoi(r.URL.Query().Get("id"))
-	sd := r.URL.Query().Get("s")
-	ed := r.URL.Query().Get("e")
+// ManageReservation signs the guest in to the reservation named by a magic link from their confirmation email
+func (rp *Repository) ManageReservation(w http.ResponseWriter, r *http.Request) {
+	code, err := magiclink.Verify(rp.App.LinkKey, chi.URLParam(r, "token"), time.Now())
+	if errors.Is(err, magiclink.ErrExpiredToken) {
+		rp.App.Session.Put(r.Context(), "error", t(r, "This link has expired, please look up your reservation instead"))
+		http.Redirect(w, r, "/reservation-lookup", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		rp.App.Session.Put(r.Context(), "error", t(r, "This link is not valid, please look up your reservation instead"))
+		http.Redirect(w, r, "/reservation-lookup", http.StatusSeeOther)
+		return
+	}
 
-	layout := "2006-01-02"
-	startDate, _ := time.Parse(layout, sd)
-	endDate, _ := time.Parse(layout, ed)
+	_ = rp.App.Session.RenewToken(r.Context())
+	rp.App.Session.Put(r.Context(), "manage_reservation_code", code)
 
-	var res models.Reservation
+	http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+}
 
-	room, err := rp.DB.GetRoomById(roomID)
+// manageLink returns the signed link a guest can follow to manage a reservation without logging in
+func (rp *Repository) manageLink(res models.Reservation) string {
+	token := magiclink.Sign(rp.App.LinkKey, res.ConfirmationCode, time.Now().Add(rp.App.LinkTTL))
+	return rp.App.BaseURL + "/manage/" + token
+}
+
+// managedReservation returns the reservation the guest looked up earlier in this session,
+// redirecting them to the lookup form when there is none
+func (rp *Repository) managedReservation(w http.ResponseWriter, r *http.Request) (models.Reservation, bool) {
+	code := rp.App.Session.GetString(r.Context(), "manage_reservation_code")
+	if code == "" {
+		rp.App.Session.Put(r.Context(), "error", t(r, "Please look up your reservation first"))
+		http.Redirect(w, r, "/reservation-lookup", http.StatusSeeOther)
+		return models.Reservation{}, false
+	}
+
+	res, err := rp.DB.GetReservationByCode(code)
+	if errors.Is(err, sql.ErrNoRows) {
+		rp.App.Session.Remove(r.Context(), "manage_reservation_code")
+		rp.App.Session.Put(r.Context(), "error", t(r, "Please look up your reservation first"))
+		http.Redirect(w, r, "/reservation-lookup", http.StatusSeeOther)
+		return res, false
+	}
 	if err != nil {
 		helpers.ServerError(w, err)
-		return
+		return res, false
 	}
 
-	res.Room = room
-	res.RoomID = roomID
-	res.StartDate = startDate
-	res.EndDate = endDate
+	return res, true
+}
 
-	rp.App.Session.Put(r.Context(), "reservation", res)
+// MyReservation shows a guest the reservation they looked up
+func (rp *Repository) MyReservation(w http.ResponseWriter, r *http.Request) {
+	res, ok := rp.managedReservation(w, r)
+	if !ok {
+		return
+	}
 
-	http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
-}
+	data := make(map[string]any)
+	data["reservation"] = res
+	data["can_cancel"] = canCancel(res, rp.App.CancellationWindow, time.Now())
+	data["cancel_deadline"] = cancelDeadline(res, rp.App.CancellationWindow)
 
-func (rp *Repository) ShowLogin(w http.ResponseWriter, r *http.Request) {
 	_ = render.Template(
-		w, r, "login.page.tmpl", &models.TemplateData{
-			Form: forms.New(nil),
+		w, r, "my-reservation.page.tmpl", &models.TemplateData{
+			Data: data,
 		},
 	)
 }
 
-// PostShowLogin handles logging the user in
-func (rp *Repository) PostShowLogin(w http.ResponseWriter, r *http.Request) {
-	_ = rp.App.Session.RenewToken(r.Context())
-
-	err := r.ParseForm()
-	if err != nil {
-		log.Println(err)
+// PostCancelReservation cancels the reservation the guest looked up, if it is still inside the cancellation window
+func (rp *Repository) PostCancelReservation(w http.ResponseWriter, r *http.Request) {
+	res, ok := rp.managedReservation(w, r)
+	if !ok {
+		return
 	}
 
-	form := forms.New(r.PostForm)
-	form.Required("email", "password")
-	form.IsEmail("email")
-
-	if !form.Valid() {
-		_ = render.Template(
-			w, r, "login.page.tmpl", &models.TemplateData{
-				Form: form,
-			},
-		)
+	if !canCancel(res, rp.App.CancellationWindow, time.Now()) {
+		rp.App.Session.Put(r.Context(), "error", t(r, "This reservation can no longer be cancelled online"))
+		http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
 		return
 	}
 
-	email := r.Form.Get("email")
-	password := r.Form.Get("password")
-
-	id, _, err := rp.DB.Authenticate(email, password)
+	mail, err := rp.cancellationMail(i18n.FromContext(r.Context()), res)
 	if err != nil {
-		log.Println(err)
-		rp.App.Session.Put(r.Context(), "error", "Invalid login credentials")
-		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = rp.DB.CancelReservation(res.ID, mail...)
+	if errors.Is(err, repository.ErrReservationCancelled) {
+		rp.App.Session.Put(r.Context(), "warning", t(r, "This reservation was already cancelled"))
+		http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rp.App.Session.Put(r.Context(), "flash", t(r, "Your reservation has been cancelled"))
+	http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+}
+
+// cancellationMail returns the mail telling the guest and the owner that a reservation has been cancelled
+func (rp *Repository) cancellationMail(l *i18n.Locale, res models.Reservation) ([]models.MailData, error) {
+	ed := &models.EmailData{
+		Reservation: res,
+		Room:        res.Room,
+		TotalPrice:  res.TotalPrice,
+	}
+
+	mail, err := rp.reservationMail(l, res.Email, "cancelled", "cancellation-notification", ed)
+	if err != nil {
+		return nil, err
+	}
+
+	// take the stay off the guest's calendar
+	mail[0].Attachments = []models.Attachment{rp.calendarInvitation(l, ical.MethodCancel, res, "")}
+
+	return mail, nil
+}
+
+// ChangeReservation shows the form where a guest picks new dates or another room for their reservation
+func (rp *Repository) ChangeReservation(w http.ResponseWriter, r *http.Request) {
+	res, ok := rp.managedReservation(w, r)
+	if !ok {
+		return
+	}
+
+	// changes are allowed for as long as cancellations are
+	if !canCancel(res, rp.App.CancellationWindow, time.Now()) {
+		rp.App.Session.Put(r.Context(), "error", t(r, "This reservation can no longer be changed online"))
+		http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+		return
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["start"] = res.StartDate.Format(forms.DateLayout)
+	stringMap["end"] = res.EndDate.Format(forms.DateLayout)
+	stringMap["room_id"] = strconv.Itoa(res.RoomID)
+
+	rp.renderChangeReservation(w, r, res, forms.New(nil), stringMap)
+}
+
+// PostChangeReservation moves the guest's reservation to the dates and room they picked, if they are free
+func (rp *Repository) PostChangeReservation(w http.ResponseWriter, r *http.Request) {
+	res, ok := rp.managedReservation(w, r)
+	if !ok {
+		return
+	}
+
+	if !canCancel(res, rp.App.CancellationWindow, time.Now()) {
+		rp.App.Session.Put(r.Context(), "error", t(r, "This reservation can no longer be changed online"))
+		http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["start"] = r.Form.Get("start")
+	stringMap["end"] = r.Form.Get("end")
+	stringMap["room_id"] = r.Form.Get("room_id")
+
+	form := newForm(r, r.PostForm)
+	form.Required("room_id")
+	startDate, endDate := form.DateRange("start", "end", time.Now())
+
+	var room models.Room
+	if form.Valid() {
+		roomID, _ := strconv.Atoi(stringMap["room_id"])
+		room, err = rp.DB.GetRoomById(roomID)
+		if err != nil || room.ID == 0 || room.Retired {
+			form.Errors.Add("room_id", t(r, "Please choose a room"))
+		} else if res.Adults+res.Children > room.Capacity {
+			form.Errors.Add("room_id", t(r, "%s sleeps at most %d guests", room.RoomName, room.Capacity))
+		}
+	}
+
+	if form.Valid() {
+		available, err := rp.DB.SearchAvailabilityByDatesByRoomIDExcluding(startDate, endDate, room.ID, res.ID)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		if !available {
+			form.Errors.Add("start", t(r, "%s is not available for these dates", room.RoomName))
+		}
+	}
+
+	if !form.Valid() {
+		rp.renderChangeReservation(w, r, res, form, stringMap)
+		return
+	}
+
+	old := res
+	res.StartDate = startDate
+	res.EndDate = endDate
+	res.RoomID = room.ID
+	res.Room = room
+
+	err = rp.priceReservation(&res)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	mail, err := rp.changeMail(i18n.FromContext(r.Context()), old, res)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = rp.DB.ModifyReservation(res, mail...)
+	if errors.Is(err, repository.ErrRoomNotAvailable) {
+		form.Errors.Add("start", t(r, "%s was just booked for these dates", room.RoomName))
+		rp.renderChangeReservation(w, r, old, form, stringMap)
+		return
+	}
+	var violation *bookingrules.Violation
+	if errors.As(err, &violation) {
+		form.Errors.Add("start", t(r, violation.Message, violation.Args...))
+		rp.renderChangeReservation(w, r, old, form, stringMap)
+		return
+	}
+	if errors.Is(err, repository.ErrReservationCancelled) {
+		rp.App.Session.Put(r.Context(), "warning", t(r, "This reservation was already cancelled"))
+		http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rp.App.Session.Put(r.Context(), "flash", t(r, "Your reservation has been changed"))
+	http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+}
+
+// changeMail returns the mail telling the guest and the owner that a reservation has been moved from old to res
+func (rp *Repository) changeMail(l *i18n.Locale, old, res models.Reservation) ([]models.MailData, error) {
+	ed := &models.EmailData{
+		Reservation: res,
+		Previous:    old,
+		Room:        res.Room,
+		TotalPrice:  res.TotalPrice,
+		ManageLink:  rp.manageLink(res),
+	}
+
+	return rp.reservationMail(l, res.Email, "changed", "change-notification", ed)
+}
+
+// reservationMail renders the guest email guestTmpl to guestEmail in the guest's locale l and the owner email
+// ownerTmpl in the default locale, both from ed and sent from the configured sender address
+func (rp *Repository) reservationMail(
+	l *i18n.Locale, guestEmail, guestTmpl, ownerTmpl string, ed *models.EmailData,
+) ([]models.MailData, error) {
+	guestMsg, err := render.Email(guestTmpl, l, ed)
+	if err != nil {
+		return nil, err
+	}
+	guestMsg.To = guestEmail
+	guestMsg.From = rp.App.MailFrom
+
+	// send notification to proper owner
+	ownerMsg, err := render.Email(ownerTmpl, i18n.Default, ed)
+	if err != nil {
+		return nil, err
+	}
+	ownerMsg.To = "me@here.com"
+	ownerMsg.From = rp.App.MailFrom
+
+	return []models.MailData{guestMsg, ownerMsg}, nil
+}
+
+// renderChangeReservation renders the change reservation form with the rooms a guest can move to
+func (rp *Repository) renderChangeReservation(
+	w http.ResponseWriter, r *http.Request, res models.Reservation, form *forms.Form, stringMap map[string]string,
+) {
+	rooms, err := rp.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	var active []models.Room
+	for _, room := range rooms {
+		if !room.Retired {
+			active = append(active, room)
+		}
+	}
+
+	data := make(map[string]any)
+	data["reservation"] = res
+	data["rooms"] = active
+
+	_ = render.Template(
+		w, r, "change-reservation.page.tmpl", &models.TemplateData{
+			Form:      form,
+			Data:      data,
+			StringMap: stringMap,
+		},
+	)
+}
+
+// cancelDeadline returns the last moment a guest can cancel a reservation themselves
+func cancelDeadline(res models.Reservation, window time.Duration) time.Time {
+	return res.StartDate.Add(-window)
+}
+
+// canCancel reports whether a guest can still cancel a reservation themselves at the time now
+func canCancel(res models.Reservation, window time.Duration, now time.Time) bool {
+	return !res.Cancelled() && now.Before(cancelDeadline(res, window))
+}
+
+// ChooseRoom displays list of available rooms
+func (rp *Repository) ChooseRoom(w http.ResponseWriter, r *http.Request) {
+	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rp.App.Session.Get(r.Context(), "reservation")
+
+	res, ok := rp.App.Session.Get(r.Context(), "reservation").(models.Reservation)
+	if !ok {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := rp.roomsByID([]int{roomID})
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if !party.Fits(rooms, res.Adults, res.Children) {
+		rp.App.Session.Put(r.Context(), "error", t(r, "The rooms you chose cannot sleep your whole party"))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	res.RoomID = roomID
+
+	rp.App.Session.Put(r.Context(), "reservation", res)
+	rp.App.Session.Remove(r.Context(), "room_ids")
+
+	http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
+}
+
+// ChooseRooms takes the rooms of a combination offered for the party to the make reservation page, to be
+// booked together
+func (rp *Repository) ChooseRooms(w http.ResponseWriter, r *http.Request) {
+	res, ok := rp.App.Session.Get(r.Context(), "reservation").(models.Reservation)
+	if !ok {
+		rp.App.Session.Put(r.Context(), "error", t(r, "can't get reservation from session"))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	var roomIDs []int
+	seen := make(map[int]bool)
+	for _, id := range r.URL.Query()["room"] {
+		roomID, err := strconv.Atoi(id)
+		if err != nil || seen[roomID] {
+			helpers.ClientError(w, http.StatusBadRequest)
+			return
+		}
+		seen[roomID] = true
+		roomIDs = append(roomIDs, roomID)
+	}
+	if len(roomIDs) == 0 || len(roomIDs) > party.MaxRooms {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	rooms, err := rp.roomsByID(roomIDs)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if !party.Fits(rooms, res.Adults, res.Children) {
+		rp.App.Session.Put(r.Context(), "error", t(r, "The rooms you chose cannot sleep your whole party"))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	res.RoomID = roomIDs[0]
+
+	rp.App.Session.Put(r.Context(), "reservation", res)
+	rp.App.Session.Put(r.Context(), "room_ids", roomIDs)
+
+	http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
+}
+
+// BookRoom takes URL parameters, builds a session variable, and takes user to make res screen
+func (rp *Repository) BookRoom(w http.ResponseWriter, r *http.Request) {
+	roomID, _ := strconv.Atoi(r.URL.Query().Get("id"))
+
+	form := forms.New(r.URL.Query())
+	startDate, endDate := form.DateRange("s", "e", time.Now())
+	if !form.Valid() {
+		rp.App.Session.Put(r.Context(), "error", t(r, "Please choose valid dates"))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	var res models.Reservation
+
+	room, err := rp.DB.GetRoomById(roomID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	res.Room = room
+	res.RoomID = roomID
+	res.StartDate = startDate
+	res.EndDate = endDate
+
+	rp.App.Session.Put(r.Context(), "reservation", res)
+	rp.App.Session.Remove(r.Context(), "room_ids")
+
+	http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
+}
+
+func (rp *Repository) ShowLogin(w http.ResponseWriter, r *http.Request) {
+	_ = render.Template(
+		w, r, "login.page.tmpl", &models.TemplateData{
+			Form: forms.New(nil),
+		},
+	)
+}
+
+// PostShowLogin handles logging the user in
+func (rp *Repository) PostShowLogin(w http.ResponseWriter, r *http.Request) {
+	_ = rp.App.Session.RenewToken(r.Context())
+
+	err := r.ParseForm()
+	if err != nil {
+		log.Println(err)
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("email", "password")
+	form.IsEmail("email")
+
+	if !form.Valid() {
+		_ = render.Template(
+			w, r, "login.page.tmpl", &models.TemplateData{
+				Form: form,
+			},
+		)
+		return
+	}
+
+	email := r.Form.Get("email")
+	password := r.Form.Get("password")
+
+	id, _, err := rp.DB.Authenticate(email, password)
+	if err != nil {
+		log.Println(err)
+		rp.App.Session.Put(r.Context(), "error", "Invalid login credentials")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 		return
 	}
 
@@ -436,3 +1244,1090 @@ func (rp *Repository) Logout(w http.ResponseWriter, r *http.Request) {
 func (rp *Repository) AdminDashboard(w http.ResponseWriter, r *http.Request) {
 	_ = render.Template(w, r, "admin-dashboard.page.tmpl", &models.TemplateData{})
 }
+
+// AdminNewReservations shows all new reservations in admin tool
+func (rp *Repository) AdminNewReservations(w http.ResponseWriter, r *http.Request) {
+	reservations, err := rp.DB.AllNewReservations()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]any)
+	data["reservations"] = reservations
+
+	_ = render.Template(
+		w, r, "admin-new-reservations.page.tmpl", &models.TemplateData{
+			Data: data,
+		},
+	)
+}
+
+// reservationsPerPage is the number of reservations shown on each page of the all reservations list
+const reservationsPerPage = 20
+
+// pageLink is a single link in a pagination bar
+type pageLink struct {
+	Number int
+	URL    string
+	Active bool
+}
+
+// AdminAllReservations shows all reservations in admin tool, one page at a time
+func (rp *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
+	filter := reservationFilterFromQuery(r.URL.Query())
+
+	reservations, total, err := rp.DB.AllReservations(filter)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := rp.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	totalPages := (total + filter.PerPage - 1) / filter.PerPage
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	var pages []pageLink
+	for i := 1; i <= totalPages; i++ {
+		f := filter
+		f.Page = i
+		pages = append(pages, pageLink{Number: i, URL: allReservationsURL(f), Active: i == filter.Page})
+	}
+
+	stringMap := make(map[string]string)
+	if !filter.From.IsZero() {
+		stringMap["from"] = filter.From.Format(forms.DateLayout)
+	}
+	if !filter.To.IsZero() {
+		stringMap["to"] = filter.To.Format(forms.DateLayout)
+	}
+	for _, column := range []string{"start_date", "last_name", "room", "created_at"} {
+		f := filter
+		f.Page = 1
+		f.SortBy = column
+		f.SortDesc = filter.SortBy == column && !filter.SortDesc
+		stringMap["sort_"+column] = allReservationsURL(f)
+	}
+	if filter.Page > 1 {
+		f := filter
+		f.Page--
+		stringMap["prev_url"] = allReservationsURL(f)
+	}
+	if filter.Page < totalPages {
+		f := filter
+		f.Page++
+		stringMap["next_url"] = allReservationsURL(f)
+	}
+
+	intMap := make(map[string]int)
+	intMap["room_id"] = filter.RoomID
+	intMap["total"] = total
+
+	data := make(map[string]any)
+	data["reservations"] = reservations
+	data["rooms"] = rooms
+	data["pages"] = pages
+
+	_ = render.Template(
+		w, r, "admin-all-reservations.page.tmpl", &models.TemplateData{
+			StringMap: stringMap,
+			IntMap:    intMap,
+			Data:      data,
+		},
+	)
+}
+
+// reservationFilterFromQuery builds a reservation filter from the all reservations page query string
+func reservationFilterFromQuery(q url.Values) models.ReservationFilter {
+	layout := forms.DateLayout
+
+	filter := models.ReservationFilter{
+		Page:     1,
+		PerPage:  reservationsPerPage,
+		SortBy:   "start_date",
+		SortDesc: q.Get("dir") == "desc",
+	}
+
+	if page, err := strconv.Atoi(q.Get("page")); err == nil && page > 0 {
+		filter.Page = page
+	}
+
+	switch q.Get("sort") {
+	case "start_date", "last_name", "room", "created_at":
+		filter.SortBy = q.Get("sort")
+	}
+
+	if from, err := time.Parse(layout, q.Get("from")); err == nil {
+		filter.From = from
+	}
+	if to, err := time.Parse(layout, q.Get("to")); err == nil {
+		filter.To = to
+	}
+
+	if roomID, err := strconv.Atoi(q.Get("room_id")); err == nil && roomID > 0 {
+		filter.RoomID = roomID
+	}
+
+	return filter
+}
+
+// allReservationsURL builds a link to the all reservations page that reproduces the given filter
+func allReservationsURL(f models.ReservationFilter) string {
+	q := url.Values{}
+	q.Set("page", strconv.Itoa(f.Page))
+	q.Set("sort", f.SortBy)
+	if f.SortDesc {
+		q.Set("dir", "desc")
+	}
+	if !f.From.IsZero() {
+		q.Set("from", f.From.Format(forms.DateLayout))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.Format(forms.DateLayout))
+	}
+	if f.RoomID > 0 {
+		q.Set("room_id", strconv.Itoa(f.RoomID))
+	}
+
+	return "/admin/reservations-all?" + q.Encode()
+}
+
+// calendarDay is one night in a room's row of the reservation calendar
+type calendarDay struct {
+	Date          time.Time
+	ReservationID int
+	BlockID       int
+}
+
+// calendarRoom is one room's row in the reservation calendar
+type calendarRoom struct {
+	Room models.Room
+	Days []calendarDay
+}
+
+// AdminReservationsCalendar displays the reservation calendar for one month
+func (rp *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Request) {
+	firstOfMonth := calendarMonth(r.URL.Query().Get("y"), r.URL.Query().Get("m"))
+	lastOfMonth := firstOfMonth.AddDate(0, 1, -1)
+
+	rooms, err := rp.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	var days []time.Time
+	for d := firstOfMonth; !d.After(lastOfMonth); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+
+	// retired rooms stay on the calendar so their history can still be seen, but read-only
+	var calendar []calendarRoom
+	for _, room := range rooms {
+		restrictions, err := rp.DB.GetRestrictionsForRoomByDate(room.ID, firstOfMonth, lastOfMonth)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		row := calendarRoom{Room: room}
+		for _, d := range days {
+			day := calendarDay{Date: d}
+			for _, rr := range restrictions {
+				// a restriction covers the nights from its start date up to, but not including, its end date
+				if d.Before(rr.StartDate) || !d.Before(rr.EndDate) {
+					continue
+				}
+				if rr.ReservationID > 0 {
+					day.ReservationID = rr.ReservationID
+				} else {
+					day.BlockID = rr.ID
+				}
+			}
+			row.Days = append(row.Days, day)
+		}
+
+		calendar = append(calendar, row)
+	}
+
+	next := firstOfMonth.AddDate(0, 1, 0)
+	last := firstOfMonth.AddDate(0, -1, 0)
+
+	stringMap := make(map[string]string)
+	stringMap["this_month"] = firstOfMonth.Format("January 2006")
+	stringMap["this_month_url"] = calendarURL(firstOfMonth)
+	stringMap["year"] = strconv.Itoa(firstOfMonth.Year())
+	stringMap["month"] = strconv.Itoa(int(firstOfMonth.Month()))
+	stringMap["next_month_url"] = calendarURL(next)
+	stringMap["last_month_url"] = calendarURL(last)
+
+	data := make(map[string]any)
+	data["calendar"] = calendar
+	data["days"] = days
+
+	_ = render.Template(
+		w, r, "admin-reservations-calendar.page.tmpl", &models.TemplateData{
+			StringMap: stringMap,
+			Data:      data,
+		},
+	)
+}
+
+// PostAdminReservationsCalendar saves the owner blocks ticked on the reservation calendar
+func (rp *Repository) PostAdminReservationsCalendar(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	firstOfMonth := calendarMonth(r.Form.Get("y"), r.Form.Get("m"))
+	lastOfMonth := firstOfMonth.AddDate(0, 1, -1)
+
+	rooms, err := rp.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	notBlocked := 0
+	for _, room := range rooms {
+		// retired rooms are shown read-only, so none of their blocks were posted
+		if room.Retired {
+			continue
+		}
+
+		restrictions, err := rp.DB.GetRestrictionsForRoomByDate(room.ID, firstOfMonth, lastOfMonth)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		// nights the admin wants blocked, and nights already covered by a block we keep
+		wanted := make(map[string]bool)
+		for d := firstOfMonth; !d.After(lastOfMonth); d = d.AddDate(0, 0, 1) {
+			if r.Form.Has(fmt.Sprintf("block_%d_%s", room.ID, d.Format(forms.DateLayout))) {
+				wanted[d.Format(forms.DateLayout)] = true
+			}
+		}
+		covered := make(map[string]bool)
+
+		for _, rr := range restrictions {
+			if rr.ReservationID > 0 {
+				continue
+			}
+
+			keep := true
+			for d := rr.StartDate; d.Before(rr.EndDate); d = d.AddDate(0, 0, 1) {
+				if !d.Before(firstOfMonth) && !d.After(lastOfMonth) && !wanted[d.Format(forms.DateLayout)] {
+					keep = false
+					break
+				}
+			}
+
+			if !keep {
+				// nights of this block outside the month are not on the form, so they stay blocked
+				var outside []time.Time
+				for d := rr.StartDate; d.Before(rr.EndDate); d = d.AddDate(0, 0, 1) {
+					if d.Before(firstOfMonth) || d.After(lastOfMonth) {
+						outside = append(outside, d)
+					}
+				}
+				err = rp.DB.ReplaceBlock(rr.ID, room.ID, outside)
+				if err != nil {
+					helpers.ServerError(w, err)
+					return
+				}
+				continue
+			}
+
+			for d := rr.StartDate; d.Before(rr.EndDate); d = d.AddDate(0, 0, 1) {
+				covered[d.Format(forms.DateLayout)] = true
+			}
+		}
+
+		for d := firstOfMonth; !d.After(lastOfMonth); d = d.AddDate(0, 0, 1) {
+			if wanted[d.Format(forms.DateLayout)] && !covered[d.Format(forms.DateLayout)] {
+				err = rp.DB.InsertBlockForRoom(room.ID, d)
+				if errors.Is(err, repository.ErrRoomNotAvailable) {
+					// the night was booked since the calendar was loaded
+					notBlocked++
+					continue
+				}
+				if err != nil {
+					helpers.ServerError(w, err)
+					return
+				}
+			}
+		}
+	}
+
+	if notBlocked > 0 {
+		rp.App.Session.Put(r.Context(), "warning", fmt.Sprintf("%d night(s) were booked meanwhile and could not be blocked", notBlocked))
+	} else {
+		rp.App.Session.Put(r.Context(), "flash", "Changes saved")
+	}
+	http.Redirect(w, r, calendarURL(firstOfMonth), http.StatusSeeOther)
+}
+
+// calendarMonth returns the first day of the calendar month given by year and month strings,
+// falling back to the current month when they are missing or invalid
+func calendarMonth(y, m string) time.Time {
+	year, yearErr := strconv.Atoi(y)
+	month, monthErr := strconv.Atoi(m)
+	if yearErr == nil && monthErr == nil && month >= 1 && month <= 12 {
+		return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// calendarURL builds a link to the reservation calendar for the month containing t
+func calendarURL(t time.Time) string {
+	return fmt.Sprintf("/admin/reservation-calendar?y=%d&m=%d", t.Year(), t.Month())
+}
+
+// AdminShowReservation shows a reservation in the admin tool
+func (rp *Repository) AdminShowReservation(w http.ResponseWriter, r *http.Request) {
+	src := chi.URLParam(r, "src")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	res, err := rp.DB.GetReservationByID(id)
+	if err != nil {
+		rp.App.Session.Put(r.Context(), "error", "can't find reservation")
+		http.Redirect(w, r, reservationListURL(src, r.URL.Query()), http.StatusSeeOther)
+		return
+	}
+
+	data := make(map[string]any)
+	data["reservation"] = res
+
+	_ = render.Template(
+		w, r, "admin-reservations-show.page.tmpl", &models.TemplateData{
+			StringMap: reservationShowStringMap(src, r.URL.Query()),
+			Data:      data,
+			Form:      forms.New(nil),
+		},
+	)
+}
+
+// AdminPostShowReservation updates the guest details of a reservation from the admin tool
+func (rp *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	src := chi.URLParam(r, "src")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	res, err := rp.DB.GetReservationByID(id)
+	if err != nil {
+		rp.App.Session.Put(r.Context(), "error", "can't find reservation")
+		http.Redirect(w, r, reservationListURL(src, r.PostForm), http.StatusSeeOther)
+		return
+	}
+
+	res.FirstName = r.Form.Get("first_name")
+	res.LastName = r.Form.Get("last_name")
+	res.Email = r.Form.Get("email")
+	res.Phone = r.Form.Get("phone")
+
+	form := forms.New(r.PostForm)
+	form.Required("first_name", "last_name", "email")
+	form.IsEmail("email")
+
+	if !form.Valid() {
+		data := make(map[string]any)
+		data["reservation"] = res
+
+		_ = render.Template(
+			w, r, "admin-reservations-show.page.tmpl", &models.TemplateData{
+				StringMap: reservationShowStringMap(src, r.PostForm),
+				Data:      data,
+				Form:      form,
+			},
+		)
+		return
+	}
+
+	err = rp.DB.UpdateReservation(res)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rp.App.Session.Put(r.Context(), "flash", "Changes saved")
+	http.Redirect(w, r, reservationListURL(src, r.PostForm), http.StatusSeeOther)
+}
+
+// AdminDeleteReservation deletes a reservation from the admin tool
+func (rp *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	src := chi.URLParam(r, "src")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = rp.DB.DeleteReservation(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rp.App.Session.Put(r.Context(), "flash", "Reservation deleted")
+	http.Redirect(w, r, reservationListURL(src, r.PostForm), http.StatusSeeOther)
+}
+
+// reservationShowStringMap holds the values the reservation page needs to return to the list it came from
+func reservationShowStringMap(src string, q url.Values) map[string]string {
+	stringMap := make(map[string]string)
+	stringMap["src"] = src
+	stringMap["year"] = q.Get("y")
+	stringMap["month"] = q.Get("m")
+	stringMap["back_url"] = reservationListURL(src, q)
+	return stringMap
+}
+
+// reservationListURL returns the admin list a reservation was opened from
+func reservationListURL(src string, q url.Values) string {
+	switch src {
+	case "new":
+		return "/admin/reservations-new"
+	case "cal":
+		return calendarURL(calendarMonth(q.Get("y"), q.Get("m")))
+	default:
+		return "/admin/reservations-all"
+	}
+}
+
+// AdminProcessReservation marks a reservation as processed, or as not processed when processed=0 is posted
+func (rp *Repository) AdminProcessReservation(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	src := chi.URLParam(r, "src")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	processed := 1
+	if r.Form.Get("processed") == "0" {
+		processed = 0
+	}
+
+	err = rp.DB.UpdateProcessedForReservation(id, processed)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rp.App.Session.Put(r.Context(), "flash", "Reservation updated")
+	http.Redirect(w, r, reservationListURL(src, r.PostForm), http.StatusSeeOther)
+}
+
+// AdminProcessReservations marks every reservation ticked on the new reservations page as processed
+func (rp *Repository) AdminProcessReservations(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	count := 0
+	for _, x := range r.PostForm["id"] {
+		id, err := strconv.Atoi(x)
+		if err != nil {
+			helpers.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		err = rp.DB.UpdateProcessedForReservation(id, 1)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		count++
+	}
+
+	if count == 0 {
+		rp.App.Session.Put(r.Context(), "warning", "No reservations selected")
+	} else {
+		rp.App.Session.Put(r.Context(), "flash", fmt.Sprintf("%d reservation(s) marked as processed", count))
+	}
+	http.Redirect(w, r, "/admin/reservations-new", http.StatusSeeOther)
+}
+
+// AdminRooms lists every room, including retired ones, in the admin tool
+func (rp *Repository) AdminRooms(w http.ResponseWriter, r *http.Request) {
+	rooms, err := rp.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]any)
+	data["rooms"] = rooms
+
+	_ = render.Template(
+		w, r, "admin-rooms.page.tmpl", &models.TemplateData{
+			Data: data,
+		},
+	)
+}
+
+// AdminNewRoom shows the form for adding a room
+func (rp *Repository) AdminNewRoom(w http.ResponseWriter, r *http.Request) {
+	room := models.Room{Capacity: 2}
+	rp.renderAdminRoom(w, r, room, roomAmounts(room), forms.New(nil))
+}
+
+// AdminShowRoom shows the form for editing a room, with its seasonal rates and booking rules
+func (rp *Repository) AdminShowRoom(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	room, err := rp.DB.GetRoomById(id)
+	if err != nil {
+		rp.App.Session.Put(r.Context(), "error", "can't find room")
+		http.Redirect(w, r, "/admin/rooms", http.StatusSeeOther)
+		return
+	}
+
+	rp.renderAdminRoom(w, r, room, roomAmounts(room), forms.New(nil))
+}
+
+// AdminPostRoom saves a new room, or changes to an existing one, from the admin tool
+func (rp *Repository) AdminPostRoom(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	var room models.Room
+	if idParam := chi.URLParam(r, "id"); idParam != "" {
+		id, err := strconv.Atoi(idParam)
+		if err != nil {
+			helpers.ClientError(w, http.StatusNotFound)
+			return
+		}
+
+		room, err = rp.DB.GetRoomById(id)
+		if err != nil {
+			rp.App.Session.Put(r.Context(), "error", "can't find room")
+			http.Redirect(w, r, "/admin/rooms", http.StatusSeeOther)
+			return
+		}
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("room_name", "capacity", "nightly_rate")
+
+	room.RoomName = strings.TrimSpace(r.Form.Get("room_name"))
+	room.Description = strings.TrimSpace(r.Form.Get("description"))
+	room.Retired = r.Form.Get("retired") == "1"
+
+	room.Slug = slugify(r.Form.Get("slug"))
+	if room.Slug == "" {
+		room.Slug = slugify(room.RoomName)
+	}
+
+	capacity, err := strconv.Atoi(r.Form.Get("capacity"))
+	if err != nil || capacity < 1 {
+		form.Errors.Add("capacity", "Capacity must be a whole number of at least 1")
+	}
+	room.Capacity = capacity
+
+	rate, ok := parseCurrency(r.Form.Get("nightly_rate"))
+	if !ok {
+		form.Errors.Add("nightly_rate", "Nightly rate must be an amount such as 129 or 129.50")
+	}
+	room.NightlyRate = rate
+
+	surcharge := 0
+	if r.Form.Get("weekend_surcharge") != "" {
+		surcharge, ok = parseCurrency(r.Form.Get("weekend_surcharge"))
+		if !ok {
+			form.Errors.Add("weekend_surcharge", "Weekend surcharge must be an amount such as 20 or 19.50")
+		}
+	}
+	room.WeekendSurcharge = surcharge
+
+	if form.Valid() {
+		if room.ID == 0 {
+			room.ID, err = rp.DB.InsertRoom(room)
+		} else {
+			err = rp.DB.UpdateRoom(room)
+		}
+
+		if errors.Is(err, repository.ErrSlugTaken) {
+			form.Errors.Add("slug", "This slug is already used by another room")
+		} else if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		stringMap := map[string]string{
+			"nightly_rate":      r.Form.Get("nightly_rate"),
+			"weekend_surcharge": r.Form.Get("weekend_surcharge"),
+		}
+		rp.renderAdminRoom(w, r, room, stringMap, form)
+		return
+	}
+
+	rp.App.Session.Put(r.Context(), "flash", "Room saved")
+	http.Redirect(w, r, "/admin/rooms", http.StatusSeeOther)
+}
+
+// AdminPostSeasonalRate adds a seasonal rate to a room
+func (rp *Repository) AdminPostSeasonalRate(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	layout := forms.DateLayout
+	season := models.SeasonalRate{
+		RoomID: roomID,
+		Name:   strings.TrimSpace(r.Form.Get("name")),
+	}
+
+	startDate, startErr := time.Parse(layout, r.Form.Get("start_date"))
+	endDate, endErr := time.Parse(layout, r.Form.Get("end_date"))
+	rate, ok := parseCurrency(r.Form.Get("nightly_rate"))
+
+	if season.Name == "" || startErr != nil || endErr != nil || endDate.Before(startDate) || !ok {
+		rp.App.Session.Put(r.Context(), "error", "Seasonal rate needs a name, a valid date range and a nightly rate")
+		http.Redirect(w, r, fmt.Sprintf("/admin/rooms/%d", roomID), http.StatusSeeOther)
+		return
+	}
+
+	season.StartDate = startDate
+	season.EndDate = endDate
+	season.NightlyRate = rate
+
+	err = rp.DB.InsertSeasonalRate(season)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rp.App.Session.Put(r.Context(), "flash", "Seasonal rate added")
+	http.Redirect(w, r, fmt.Sprintf("/admin/rooms/%d", roomID), http.StatusSeeOther)
+}
+
+// AdminDeleteSeasonalRate removes a seasonal rate from a room
+func (rp *Repository) AdminDeleteSeasonalRate(w http.ResponseWriter, r *http.Request) {
+	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	seasonID, err := strconv.Atoi(chi.URLParam(r, "seasonID"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	err = rp.DB.DeleteSeasonalRate(seasonID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rp.App.Session.Put(r.Context(), "flash", "Seasonal rate removed")
+	http.Redirect(w, r, fmt.Sprintf("/admin/rooms/%d", roomID), http.StatusSeeOther)
+}
+
+// AdminPostBookingRules saves the booking rules of a room; empty fields mean no limit
+func (rp *Repository) AdminPostBookingRules(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	rules := models.BookingRules{RoomID: roomID}
+	valid := true
+	for field, dest := range map[string]*int{
+		"min_nights":         &rules.MinNights,
+		"max_nights":         &rules.MaxNights,
+		"weekend_min_nights": &rules.WeekendMinNights,
+		"lead_time_hours":    &rules.LeadTimeHours,
+	} {
+		value := strings.TrimSpace(r.Form.Get(field))
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			valid = false
+		}
+		*dest = n
+	}
+
+	for _, day := range r.Form["no_arrival_days"] {
+		d, err := strconv.Atoi(day)
+		if err != nil || d < int(time.Sunday) || d > int(time.Saturday) {
+			valid = false
+			continue
+		}
+		rules.NoArrivalDays |= 1 << d
+	}
+
+	if rules.MaxNights > 0 && rules.MaxNights < rules.MinNights {
+		valid = false
+	}
+
+	if !valid {
+		rp.App.Session.Put(r.Context(), "error", "Booking rules must be whole numbers, with the maximum stay no shorter than the minimum")
+		http.Redirect(w, r, fmt.Sprintf("/admin/rooms/%d", roomID), http.StatusSeeOther)
+		return
+	}
+
+	err = rp.DB.UpdateBookingRules(rules)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rp.App.Session.Put(r.Context(), "flash", "Booking rules saved")
+	http.Redirect(w, r, fmt.Sprintf("/admin/rooms/%d", roomID), http.StatusSeeOther)
+}
+
+// renderAdminRoom renders the room form; stringMap holds the money fields as typed by the admin
+func (rp *Repository) renderAdminRoom(w http.ResponseWriter, r *http.Request, room models.Room, stringMap map[string]string, form *forms.Form) {
+	var seasons []models.SeasonalRate
+	var rules models.BookingRules
+	if room.ID > 0 {
+		var err error
+		seasons, err = rp.DB.GetSeasonalRatesForRoom(room.ID)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		rules, err = rp.DB.GetBookingRulesForRoom(room.ID)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	data := make(map[string]any)
+	data["room"] = room
+	data["seasons"] = seasons
+	data["rules"] = rules
+	data["weekdays"] = []time.Weekday{
+		time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday,
+	}
+
+	_ = render.Template(
+		w, r, "admin-room.page.tmpl", &models.TemplateData{
+			Data:      data,
+			StringMap: stringMap,
+			Form:      form,
+		},
+	)
+}
+
+// roomAmounts returns a room's money fields formatted for its edit form
+func roomAmounts(room models.Room) map[string]string {
+	stringMap := make(map[string]string)
+	if room.ID > 0 {
+		stringMap["nightly_rate"] = fmt.Sprintf("%d.%02d", room.NightlyRate/100, room.NightlyRate%100)
+		stringMap["weekend_surcharge"] = fmt.Sprintf("%d.%02d", room.WeekendSurcharge/100, room.WeekendSurcharge%100)
+	}
+	return stringMap
+}
+
+// AdminDeleteRoom retires a room so it can no longer be booked
+func (rp *Repository) AdminDeleteRoom(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	err = rp.DB.DeleteRoom(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rp.App.Session.Put(r.Context(), "flash", "Room retired")
+	http.Redirect(w, r, "/admin/rooms", http.StatusSeeOther)
+}
+
+// AdminAPIKeys lists the API keys and shows the form for issuing a new one
+func (rp *Repository) AdminAPIKeys(w http.ResponseWriter, r *http.Request) {
+	rp.renderAdminAPIKeys(w, r, forms.New(nil), models.APIKey{})
+}
+
+// AdminPostAPIKey issues a new API key and shows it once; only its hash is kept
+func (rp *Repository) AdminPostAPIKey(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("name")
+
+	k := models.APIKey{Name: strings.TrimSpace(r.Form.Get("name"))}
+	for _, scope := range models.APIScopes {
+		for _, picked := range r.Form["scope"] {
+			if picked == scope {
+				k.Scopes = append(k.Scopes, scope)
+			}
+		}
+	}
+	if len(k.Scopes) == 0 {
+		form.Errors.Add("scope", "Pick at least one scope")
+	}
+
+	if !form.Valid() {
+		rp.renderAdminAPIKeys(w, r, form, k)
+		return
+	}
+
+	key, prefix, hash, err := apikey.New()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	k.Prefix = prefix
+	k.KeyHash = hash
+
+	_, err = rp.DB.InsertAPIKey(k)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rp.App.Session.Put(r.Context(), "new_api_key", key)
+	rp.App.Session.Put(r.Context(), "flash", fmt.Sprintf("API key %q issued", k.Name))
+	http.Redirect(w, r, "/admin/api-keys", http.StatusSeeOther)
+}
+
+// AdminRevokeAPIKey revokes an API key so it can no longer be used
+func (rp *Repository) AdminRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	err = rp.DB.RevokeAPIKey(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rp.App.Session.Put(r.Context(), "flash", "API key revoked")
+	http.Redirect(w, r, "/admin/api-keys", http.StatusSeeOther)
+}
+
+// renderAdminAPIKeys renders the API keys page, showing a newly issued key once
+func (rp *Repository) renderAdminAPIKeys(w http.ResponseWriter, r *http.Request, form *forms.Form, k models.APIKey) {
+	keys, err := rp.DB.AllAPIKeys()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	picked := make(map[string]bool)
+	for _, scope := range k.Scopes {
+		picked[scope] = true
+	}
+
+	data := make(map[string]any)
+	data["keys"] = keys
+	data["scopes"] = models.APIScopes
+	data["picked"] = picked
+
+	stringMap := make(map[string]string)
+	stringMap["name"] = k.Name
+	stringMap["new_api_key"] = rp.App.Session.PopString(r.Context(), "new_api_key")
+
+	_ = render.Template(
+		w, r, "admin-api-keys.page.tmpl", &models.TemplateData{
+			Form:      form,
+			Data:      data,
+			StringMap: stringMap,
+		},
+	)
+}
+
+// AdminOutbox lists the queued mail, optionally only the messages with the status in the query
+func (rp *Repository) AdminOutbox(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != models.OutboxPending && status != models.OutboxSent && status != models.OutboxDead {
+		status = ""
+	}
+
+	messages, err := rp.DB.AllOutboxMessages(status)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]any)
+	data["messages"] = messages
+	data["statuses"] = []string{models.OutboxPending, models.OutboxDead, models.OutboxSent}
+
+	stringMap := make(map[string]string)
+	stringMap["status"] = status
+
+	_ = render.Template(
+		w, r, "admin-outbox.page.tmpl", &models.TemplateData{
+			Data:      data,
+			StringMap: stringMap,
+		},
+	)
+}
+
+// AdminShowOutboxMessage shows a queued message, with the error from its last failed attempt
+func (rp *Repository) AdminShowOutboxMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	m, err := rp.DB.GetOutboxMessageByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		rp.App.Session.Put(r.Context(), "error", "can't find message")
+		http.Redirect(w, r, "/admin/outbox", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]any)
+	data["message"] = m
+
+	_ = render.Template(
+		w, r, "admin-outbox-show.page.tmpl", &models.TemplateData{
+			Data: data,
+		},
+	)
+}
+
+// AdminResendOutboxMessage queues a message to be sent again straight away, typically one that has gone dead
+func (rp *Repository) AdminResendOutboxMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	err = rp.DB.ResendOutboxMessage(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rp.App.Session.Put(r.Context(), "flash", "Message queued to be sent again")
+	http.Redirect(w, r, fmt.Sprintf("/admin/outbox/%d", id), http.StatusSeeOther)
+}
+
+// slugify turns a room name into a URL slug, e.g. "Major's Suite" into "majors-suite"
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(strings.TrimSpace(s)) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+			b.WriteRune(c)
+			dash = false
+		case c == '\'' || c == '’':
+			// drop apostrophes so "Major's" becomes "majors"
+		default:
+			if !dash && b.Len() > 0 {
+				b.WriteByte('-')
+				dash = true
+			}
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// parseCurrency parses a dollar amount such as "129" or "129.50" into cents
+func parseCurrency(s string) (int, bool) {
+	dollars, cents, hasCents := strings.Cut(strings.TrimPrefix(strings.TrimSpace(s), "$"), ".")
+
+	d, err := strconv.Atoi(dollars)
+	if err != nil || d < 0 {
+		return 0, false
+	}
+
+	c := 0
+	if hasCents {
+		if len(cents) == 1 {
+			cents += "0"
+		}
+		if len(cents) != 2 {
+			return 0, false
+		}
+		c, err = strconv.Atoi(cents)
+		if err != nil || c < 0 {
+			return 0, false
+		}
+	}
+
+	return d*100 + c, true
+}
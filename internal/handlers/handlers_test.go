@@ -2,11 +2,18 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"learn-golang/internal/i18n"
+	"learn-golang/internal/magiclink"
 	"learn-golang/internal/models"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -29,6 +36,12 @@ var theTests = []struct {
 		method:             "GET",
 		expectedStatusCode: http.StatusOK,
 	},
+	{
+		name:               "language",
+		url:                "/language?lang=fr&return=/about",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
 	{
 		name:               "about",
 		url:                "/about",
@@ -47,6 +60,36 @@ var theTests = []struct {
 		method:             "GET",
 		expectedStatusCode: http.StatusOK,
 	},
+	{
+		name:               "rooms",
+		url:                "/rooms",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "room",
+		url:                "/rooms/majors-suite",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "missing-room",
+		url:                "/rooms/broom-cupboard",
+		method:             "GET",
+		expectedStatusCode: http.StatusNotFound,
+	},
+	{
+		name:               "reservation-lookup",
+		url:                "/reservation-lookup",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "my-reservation-without-lookup",
+		url:                "/my-reservation",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
 	{
 		name:               "search-availability",
 		url:                "/search-availability",
@@ -59,6 +102,264 @@ var theTests = []struct {
 		method:             "GET",
 		expectedStatusCode: http.StatusOK,
 	},
+	{
+		name:               "admin-dashboard",
+		url:                "/admin/dashboard",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-new-reservations",
+		url:                "/admin/reservations-new",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-all-reservations",
+		url:                "/admin/reservations-all?page=2&sort=last_name&dir=desc&from=2050-01-01&room_id=1",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-reservation-calendar",
+		url:                "/admin/reservation-calendar",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-reservation-calendar-month",
+		url:                "/admin/reservation-calendar?y=2050&m=2",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:   "post-admin-reservation-calendar",
+		url:    "/admin/reservation-calendar",
+		method: "POST",
+		params: []postData{
+			{key: "y", value: "2050"},
+			{key: "m", value: "2"},
+			{key: "block_1_2050-02-10", value: "on"},
+			{key: "block_1_2050-02-06", value: "on"},
+		},
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-show-reservation",
+		url:                "/admin/reservations/new/1",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-show-missing-reservation",
+		url:                "/admin/reservations/cal/100?y=2050&m=2",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:   "admin-post-show-reservation",
+		url:    "/admin/reservations/all/1",
+		method: "POST",
+		params: []postData{
+			{key: "first_name", value: "John"},
+			{key: "last_name", value: "Smith"},
+			{key: "email", value: "john@smith.com"},
+			{key: "phone", value: "555-555-5555"},
+		},
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:   "admin-post-show-reservation-invalid",
+		url:    "/admin/reservations/all/1",
+		method: "POST",
+		params: []postData{
+			{key: "first_name", value: "John"},
+			{key: "email", value: "not-an-email"},
+		},
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:   "admin-delete-reservation",
+		url:    "/admin/reservations/cal/1/delete",
+		method: "POST",
+		params: []postData{
+			{key: "y", value: "2050"},
+			{key: "m", value: "2"},
+		},
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:   "admin-process-reservation",
+		url:    "/admin/reservations/new/1/processed",
+		method: "POST",
+		params: []postData{
+			{key: "processed", value: "1"},
+		},
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:   "admin-process-reservations",
+		url:    "/admin/reservations-new/processed",
+		method: "POST",
+		params: []postData{
+			{key: "id", value: "1"},
+			{key: "id", value: "2"},
+		},
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:   "admin-process-reservations-bad-id",
+		url:    "/admin/reservations-new/processed",
+		method: "POST",
+		params: []postData{
+			{key: "id", value: "x"},
+		},
+		expectedStatusCode: http.StatusBadRequest,
+	},
+	{
+		name:               "admin-rooms",
+		url:                "/admin/rooms",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-api-keys",
+		url:                "/admin/api-keys",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-revoke-api-key",
+		url:                "/admin/api-keys/1/revoke",
+		method:             "POST",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-outbox",
+		url:                "/admin/outbox",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-outbox-dead",
+		url:                "/admin/outbox?status=dead",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-show-outbox-message",
+		url:                "/admin/outbox/2",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-show-missing-outbox-message",
+		url:                "/admin/outbox/100",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-resend-outbox-message",
+		url:                "/admin/outbox/2/resend",
+		method:             "POST",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-new-room",
+		url:                "/admin/rooms/new",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-show-room",
+		url:                "/admin/rooms/2",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:   "admin-post-new-room",
+		url:    "/admin/rooms/new",
+		method: "POST",
+		params: []postData{
+			{key: "room_name", value: "Colonel's Loft"},
+			{key: "capacity", value: "3"},
+			{key: "nightly_rate", value: "149.50"},
+		},
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:   "admin-post-room-invalid",
+		url:    "/admin/rooms/1",
+		method: "POST",
+		params: []postData{
+			{key: "room_name", value: "General's Quarters"},
+			{key: "capacity", value: "0"},
+			{key: "nightly_rate", value: "lots"},
+		},
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:   "admin-post-room-slug-taken",
+		url:    "/admin/rooms/1",
+		method: "POST",
+		params: []postData{
+			{key: "room_name", value: "General's Quarters"},
+			{key: "slug", value: "majors-suite"},
+			{key: "capacity", value: "2"},
+			{key: "nightly_rate", value: "89"},
+		},
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-delete-room",
+		url:                "/admin/rooms/2/delete",
+		method:             "POST",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:   "admin-post-seasonal-rate",
+		url:    "/admin/rooms/1/seasons",
+		method: "POST",
+		params: []postData{
+			{key: "name", value: "Summer"},
+			{key: "start_date", value: "2050-06-01"},
+			{key: "end_date", value: "2050-08-31"},
+			{key: "nightly_rate", value: "150"},
+		},
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:   "admin-post-seasonal-rate-invalid",
+		url:    "/admin/rooms/1/seasons",
+		method: "POST",
+		params: []postData{
+			{key: "name", value: "Summer"},
+			{key: "start_date", value: "2050-08-31"},
+			{key: "end_date", value: "2050-06-01"},
+			{key: "nightly_rate", value: "150"},
+		},
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-delete-seasonal-rate",
+		url:                "/admin/rooms/1/seasons/1/delete",
+		method:             "POST",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:   "admin-post-booking-rules",
+		url:    "/admin/rooms/1/rules",
+		method: "POST",
+		params: []postData{
+			{key: "min_nights", value: "2"},
+			{key: "max_nights", value: ""},
+			{key: "weekend_min_nights", value: "3"},
+			{key: "lead_time_hours", value: "24"},
+			{key: "no_arrival_days", value: "0"},
+			{key: "no_arrival_days", value: "6"},
+		},
+		expectedStatusCode: http.StatusOK,
+	},
 	// {
 	//     name:   "post-search-availability",
 	//     url:    "/search-availability",
@@ -125,6 +426,84 @@ func TestHandlers(t *testing.T) {
 	}
 }
 
+func TestLocale(t *testing.T) {
+	routes := getRoutes()
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		cookie         string
+		expected       string
+	}{
+		{"default", "", "", "Welcome to Fort Smythe Bed and Breakfast"},
+		{"accept-language", "fr-FR,fr;q=0.9,en;q=0.8", "", "Bienvenue au Bed and Breakfast de Fort Smythe"},
+		{"cookie over accept-language", "fr-FR", "es", "Bienvenido al Bed and Breakfast de Fort Smythe"},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("GET", ts.URL+"/", nil)
+		if e.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", e.acceptLanguage)
+		}
+		if e.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: i18n.CookieName, Value: e.cookie})
+		}
+
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+
+		if !strings.Contains(string(body), e.expected) {
+			t.Errorf("%s: expected the home page to contain %q", e.name, e.expected)
+		}
+	}
+}
+
+func TestRepository_Language(t *testing.T) {
+	tests := []struct {
+		name             string
+		query            string
+		expectedCookie   string
+		expectedLocation string
+	}{
+		{"french", "lang=fr&return=/rooms?page=2", "fr", "/rooms?page=2"},
+		{"unsupported", "lang=de&return=/rooms", "", "/rooms"},
+		{"no return", "lang=es", "es", "/"},
+		{"other site", "lang=es&return=//evil.example.com", "es", "/"},
+		{"other site with backslash", "lang=es&return=/%5Cevil.example.com", "es", "/"},
+		{"absolute", "lang=es&return=https://evil.example.com", "es", "/"},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("GET", "/language?"+e.query, nil)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.Language)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("%s: Language returned wrong status code: got %d, want %d", e.name, rr.Code, http.StatusSeeOther)
+		}
+		if rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %s, got %s", e.name, e.expectedLocation, rr.Header().Get("Location"))
+		}
+
+		var got string
+		for _, c := range rr.Result().Cookies() {
+			if c.Name == i18n.CookieName {
+				got = c.Value
+			}
+		}
+		if got != e.expectedCookie {
+			t.Errorf("%s: expected lang cookie %q, got %q", e.name, e.expectedCookie, got)
+		}
+	}
+}
+
 func TestRepository_Reservation(t *testing.T) {
 	reservation := models.Reservation{
 		RoomID: 1,
@@ -176,6 +555,862 @@ func TestRepository_Reservation(t *testing.T) {
 	}
 }
 
+func TestRepository_PostReservation(t *testing.T) {
+	tests := []struct {
+		name             string
+		roomID           int
+		postedData       url.Values
+		expectedCode     int
+		expectedLocation string
+		expectedMail     []string
+		expectedError    string
+		expectedBody     string
+		locale           string
+		year             int
+	}{
+		{
+			name:   "valid",
+			roomID: 1,
+			postedData: url.Values{
+				"first_name": {"John"},
+				"last_name":  {"Smith"},
+				"email":      {"john@smith.com"},
+				"phone":      {"555-555-5555"},
+			},
+			expectedCode:     http.StatusSeeOther,
+			expectedLocation: "/reservation-summary",
+			expectedMail:     []string{"Reservation Confirmation", "Reservation Notification"},
+		},
+		{
+			name:   "valid in French",
+			roomID: 1,
+			postedData: url.Values{
+				"first_name": {"Jean"},
+				"last_name":  {"Dupont"},
+				"email":      {"jean@dupont.fr"},
+			},
+			expectedCode:     http.StatusSeeOther,
+			expectedLocation: "/reservation-summary",
+			// the owner is written to in English whatever the guest reads
+			expectedMail: []string{"Confirmation de réservation", "Reservation Notification"},
+			locale:       "fr",
+		},
+		{
+			name:   "room taken meanwhile",
+			roomID: 2,
+			postedData: url.Values{
+				"first_name": {"John"},
+				"last_name":  {"Smith"},
+				"email":      {"john@smith.com"},
+			},
+			expectedCode:     http.StatusSeeOther,
+			expectedLocation: "/search-availability",
+		},
+		{
+			name:   "booking rules changed meanwhile",
+			roomID: 1,
+			postedData: url.Values{
+				"first_name": {"Jean"},
+				"last_name":  {"Dupont"},
+				"email":      {"jean@dupont.fr"},
+			},
+			expectedCode:     http.StatusSeeOther,
+			expectedLocation: "/search-availability",
+			expectedError:    "Les séjours dans cette chambre doivent durer au moins 3 nuits",
+			locale:           "fr",
+			year:             2053,
+		},
+		{
+			name:   "insert fails",
+			roomID: 3,
+			postedData: url.Values{
+				"first_name": {"John"},
+				"last_name":  {"Smith"},
+				"email":      {"john@smith.com"},
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:   "invalid form",
+			roomID: 1,
+			postedData: url.Values{
+				"first_name": {"J"},
+				"email":      {"john"},
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "invalid form in French",
+			roomID: 1,
+			postedData: url.Values{
+				"first_name": {"J"},
+				"email":      {"jean@dupont.fr"},
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "Ce champ doit comporter au moins 3 caractères",
+			locale:       "fr",
+		},
+	}
+
+	for _, e := range tests {
+		if e.year == 0 {
+			e.year = 2050
+		}
+		reservation := models.Reservation{
+			RoomID:    e.roomID,
+			StartDate: time.Date(e.year, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(e.year, 1, 3, 0, 0, 0, 0, time.UTC),
+		}
+
+		req, _ := http.NewRequest("POST", "/make-reservation", strings.NewReader(e.postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := getCtx(req)
+		if l, ok := i18n.Lookup(e.locale); ok {
+			ctx = i18n.NewContext(ctx, l)
+		}
+		req = req.WithContext(ctx)
+		session.Put(ctx, "reservation", reservation)
+		sentMail.Reset()
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostReservation)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: PostReservation returned wrong status code: got %d, want %d", e.name, rr.Code, e.expectedCode)
+		}
+		if e.expectedLocation != "" && rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %s, got %s", e.name, e.expectedLocation, rr.Header().Get("Location"))
+		}
+		if e.expectedError != "" && session.GetString(ctx, "error") != e.expectedError {
+			t.Errorf("%s: expected error %q, got %q", e.name, e.expectedError, session.GetString(ctx, "error"))
+		}
+		if !strings.Contains(rr.Body.String(), e.expectedBody) {
+			t.Errorf("%s: expected the page to contain %q", e.name, e.expectedBody)
+		}
+		checkSentMail(t, e.name, e.expectedMail)
+
+		// the guest's confirmation carries their booking details
+		sent := sentMail.Sent()
+		if len(sent) > 0 && (sent[0].To != e.postedData.Get("email") || sent[0].From != testApp.MailFrom ||
+			!strings.Contains(sent[0].Content, i18n.FromContext(ctx).T("Dear %s,", e.postedData.Get("first_name"))) ||
+			!strings.Contains(sent[0].Content, testApp.BaseURL+"/manage/")) {
+			t.Errorf("%s: unexpected confirmation email: %+v", e.name, sent[0])
+		}
+		if len(sent) > 0 {
+			checkInvitation(t, e.name, sent[0], "REQUEST", "DTSTART;VALUE=DATE:20500101", "DTEND;VALUE=DATE:20500103")
+			if len(sent[1].Attachments) != 0 {
+				t.Errorf("%s: expected no calendar invitation for the owner", e.name)
+			}
+		}
+	}
+}
+
+// checkSentMail checks that the mail handed to the mailer had the expected subjects, in order
+func checkSentMail(t *testing.T, name string, expected []string) {
+	t.Helper()
+
+	var subjects []string
+	for _, m := range sentMail.Sent() {
+		subjects = append(subjects, m.Subject)
+	}
+	if strings.Join(subjects, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("%s: expected mail %q, got %q", name, expected, subjects)
+	}
+}
+
+func TestRepository_ReservationSummary(t *testing.T) {
+	reservation := models.Reservation{
+		RoomID:    1,
+		StartDate: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2050, 1, 3, 0, 0, 0, 0, time.UTC),
+		Room:      models.Room{ID: 1, RoomName: "General's Quarters", NightlyRate: 8900},
+	}
+	_ = Repo.priceReservation(&reservation)
+
+	if reservation.TotalPrice != 20000+8900 {
+		t.Fatalf("expected seasonal and base rate to total %d, got %d", 20000+8900, reservation.TotalPrice)
+	}
+
+	req, _ := http.NewRequest("GET", "/reservation-summary", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	session.Put(ctx, "reservation", reservation)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.ReservationSummary)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("ReservationSummary returned wrong status code: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	for _, expected := range []string{"January 3, 2050", "New Year", "$289.00"} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("expected summary to contain %q", expected)
+		}
+	}
+
+	// the same summary for a guest reading French
+	fr, _ := i18n.Lookup("fr")
+	req, _ = http.NewRequest("GET", "/reservation-summary", nil)
+	ctx = i18n.NewContext(getCtx(req), fr)
+	req = req.WithContext(ctx)
+	session.Put(ctx, "reservation", reservation)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	for _, expected := range []string{`lang="fr"`, "Récapitulatif de la réservation", "3 janvier 2050"} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("expected French summary to contain %q", expected)
+		}
+	}
+}
+
+func TestRepository_PostReservationLookup(t *testing.T) {
+	tests := []struct {
+		name             string
+		code             string
+		email            string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{"found", "ABCD2345", "john@smith.com", http.StatusSeeOther, "/my-reservation"},
+		{"found ignoring case", " abcd2345 ", "John@Smith.com", http.StatusSeeOther, "/my-reservation"},
+		{"wrong email", "ABCD2345", "jane@smith.com", http.StatusOK, ""},
+		{"unknown code", "ZZZZ2345", "john@smith.com", http.StatusOK, ""},
+		{"missing code", "", "john@smith.com", http.StatusOK, ""},
+	}
+
+	for _, e := range tests {
+		postedData := url.Values{"confirmation_code": {e.code}, "email": {e.email}}
+		req, _ := http.NewRequest("POST", "/reservation-lookup", strings.NewReader(postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostReservationLookup)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: PostReservationLookup returned wrong status code: got %d, want %d", e.name, rr.Code, e.expectedCode)
+		}
+		if e.expectedLocation != "" && rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %s, got %s", e.name, e.expectedLocation, rr.Header().Get("Location"))
+		}
+		if e.expectedCode == http.StatusSeeOther && session.GetString(ctx, "manage_reservation_code") != "ABCD2345" {
+			t.Errorf("%s: expected the confirmation code to be kept in the session", e.name)
+		}
+	}
+}
+
+func TestRepository_MyReservation(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/my-reservation", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	session.Put(ctx, "manage_reservation_code", "ABCD2345")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.MyReservation)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("MyReservation returned wrong status code: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	for _, expected := range []string{"ABCD2345", "Cancel Reservation"} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("expected reservation page to contain %q", expected)
+		}
+	}
+}
+
+func TestRepository_PostCancelReservation(t *testing.T) {
+	tests := []struct {
+		name             string
+		code             string
+		expectedLocation string
+		expectedFlash    string
+		expectedMail     []string
+	}{
+		{"inside window", "ABCD2345", "/my-reservation", "flash", []string{"Reservation Cancelled", "Cancellation Notification"}},
+		{"too close to arrival", "LATE2345", "/my-reservation", "error", nil},
+		{"already cancelled", "GONE2345", "/my-reservation", "error", nil},
+		{"not looked up", "", "/reservation-lookup", "error", nil},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/my-reservation/cancel", nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		if e.code != "" {
+			session.Put(ctx, "manage_reservation_code", e.code)
+		}
+		sentMail.Reset()
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostCancelReservation)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("%s: PostCancelReservation returned wrong status code: got %d, want %d", e.name, rr.Code, http.StatusSeeOther)
+		}
+		if rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %s, got %s", e.name, e.expectedLocation, rr.Header().Get("Location"))
+		}
+		if !session.Exists(ctx, e.expectedFlash) {
+			t.Errorf("%s: expected a %s message in the session", e.name, e.expectedFlash)
+		}
+		checkSentMail(t, e.name, e.expectedMail)
+		if sent := sentMail.Sent(); len(sent) > 0 {
+			checkInvitation(t, e.name, sent[0], "CANCEL", "STATUS:CANCELLED", "SEQUENCE:1")
+		}
+	}
+}
+
+// checkInvitation checks msg carries a calendar invitation sent with method that contains each of expected
+func checkInvitation(t *testing.T, name string, msg models.MailData, method string, expected ...string) {
+	t.Helper()
+
+	if len(msg.Attachments) != 1 {
+		t.Errorf("%s: expected 1 calendar invitation, got %d attachments", name, len(msg.Attachments))
+		return
+	}
+
+	a := msg.Attachments[0]
+	if !strings.HasSuffix(a.Name, ".ics") || !strings.Contains(a.ContentType, "method="+method) {
+		t.Errorf("%s: unexpected calendar attachment %s (%s)", name, a.Name, a.ContentType)
+	}
+	organizer := "ORGANIZER:mailto:" + testApp.MailFrom
+	for _, want := range append([]string{"METHOD:" + method, "LOCATION:1 Fort Road\\, Smythe", organizer}, expected...) {
+		if !strings.Contains(string(a.Data), want) {
+			t.Errorf("%s: expected the calendar invitation to contain %q, got:\n%s", name, want, a.Data)
+		}
+	}
+}
+
+func TestRepository_ManageReservation(t *testing.T) {
+	tests := []struct {
+		name             string
+		token            string
+		expectedLocation string
+	}{
+		{"valid", magiclink.Sign(testApp.LinkKey, "ABCD2345", time.Now().Add(time.Hour)), "/my-reservation"},
+		{"expired", magiclink.Sign(testApp.LinkKey, "ABCD2345", time.Now().Add(-time.Hour)), "/reservation-lookup"},
+		{"tampered", magiclink.Sign([]byte("another key"), "ABCD2345", time.Now().Add(time.Hour)), "/reservation-lookup"},
+		{"garbage", "not-a-token", "/reservation-lookup"},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("GET", "/manage/"+e.token, nil)
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("token", e.token)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.ManageReservation)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("%s: ManageReservation returned wrong status code: got %d, want %d", e.name, rr.Code, http.StatusSeeOther)
+		}
+		if rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %s, got %s", e.name, e.expectedLocation, rr.Header().Get("Location"))
+		}
+		hasCode := session.GetString(req.Context(), "manage_reservation_code") == "ABCD2345"
+		if hasCode != (e.expectedLocation == "/my-reservation") {
+			t.Errorf("%s: unexpected confirmation code in session: %v", e.name, hasCode)
+		}
+	}
+}
+
+func TestRepository_ManageLink(t *testing.T) {
+	link := Repo.manageLink(models.Reservation{ConfirmationCode: "ABCD2345"})
+
+	if !strings.HasPrefix(link, "http://localhost:8080/manage/") {
+		t.Fatalf("expected link under the base URL, got %s", link)
+	}
+	token := strings.TrimPrefix(link, "http://localhost:8080/manage/")
+	code, err := magiclink.Verify(testApp.LinkKey, token, time.Now())
+	if err != nil || code != "ABCD2345" {
+		t.Errorf("expected link to carry ABCD2345, got %q, %v", code, err)
+	}
+}
+
+func TestRepository_ChangeReservation(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/my-reservation/change", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	session.Put(ctx, "manage_reservation_code", "ABCD2345")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.ChangeReservation)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("ChangeReservation returned wrong status code: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	for _, expected := range []string{`value="2050-01-01"`, "Major&#39;s Suite"} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("expected change form to contain %q", expected)
+		}
+	}
+}
+
+func TestRepository_PostChangeReservation(t *testing.T) {
+	tests := []struct {
+		name             string
+		code             string
+		start            string
+		end              string
+		roomID           string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{"one day later", "ABCD2345", "2050-01-02", "2050-01-04", "1", http.StatusSeeOther, "/my-reservation"},
+		{"room not available", "ABCD2345", "2050-01-02", "2050-01-04", "2", http.StatusOK, ""},
+		{"party too large for the room", "PARTY234", "2050-01-02", "2050-01-04", "1", http.StatusOK, ""},
+		{"taken meanwhile", "ABCD2345", "2051-01-02", "2051-01-04", "1", http.StatusOK, ""},
+		{"cancelled meanwhile", "ABCD2345", "2052-01-02", "2052-01-04", "1", http.StatusSeeOther, "/my-reservation"},
+		{"booking rules changed meanwhile", "ABCD2345", "2053-01-02", "2053-01-04", "1", http.StatusOK, ""},
+		{"departure before arrival", "ABCD2345", "2050-01-04", "2050-01-02", "1", http.StatusOK, ""},
+		{"invalid date", "ABCD2345", "tomorrow", "2050-01-02", "1", http.StatusOK, ""},
+		{"unknown room", "ABCD2345", "2050-01-02", "2050-01-04", "9", http.StatusOK, ""},
+		{"too close to arrival", "LATE2345", "2050-01-02", "2050-01-04", "1", http.StatusSeeOther, "/my-reservation"},
+	}
+
+	for _, e := range tests {
+		postedData := url.Values{"start": {e.start}, "end": {e.end}, "room_id": {e.roomID}}
+		req, _ := http.NewRequest("POST", "/my-reservation/change", strings.NewReader(postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		session.Put(ctx, "manage_reservation_code", e.code)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostChangeReservation)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: PostChangeReservation returned wrong status code: got %d, want %d", e.name, rr.Code, e.expectedCode)
+		}
+		if e.expectedLocation != "" && rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %s, got %s", e.name, e.expectedLocation, rr.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRepository_PostAvailability(t *testing.T) {
+	tests := []struct {
+		name         string
+		start        string
+		end          string
+		adults       string
+		children     string
+		expectedCode int
+	}{
+		{"valid", "2050-01-01", "2050-01-03", "2", "", http.StatusOK},
+		{"missing dates", "", "", "2", "", http.StatusUnprocessableEntity},
+		{"invalid date", "soon", "2050-01-03", "2", "", http.StatusUnprocessableEntity},
+		{"departure before arrival", "2050-01-03", "2050-01-01", "2", "", http.StatusUnprocessableEntity},
+		{"in the past", "2020-01-01", "2020-01-03", "2", "", http.StatusUnprocessableEntity},
+		{"too long", "2050-01-01", "2051-01-01", "2", "", http.StatusUnprocessableEntity},
+		{"family in two rooms", "2050-01-01", "2050-01-03", "2", "3", http.StatusOK},
+		{"party too large for the rooms", "2050-01-01", "2050-01-03", "4", "3", http.StatusSeeOther},
+		{"no adults", "2050-01-01", "2050-01-03", "0", "2", http.StatusUnprocessableEntity},
+		{"party too large to search", "2050-01-01", "2050-01-03", "8", "8", http.StatusUnprocessableEntity},
+		{"nothing free", "2051-01-01", "2051-01-03", "2", "", http.StatusSeeOther},
+	}
+
+	for _, e := range tests {
+		postedData := url.Values{"start": {e.start}, "end": {e.end}, "adults": {e.adults}, "children": {e.children}}
+		req, _ := http.NewRequest("POST", "/search-availability", strings.NewReader(postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(getCtx(req))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostAvailability)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: PostAvailability returned wrong status code: got %d, want %d", e.name, rr.Code, e.expectedCode)
+		}
+	}
+}
+
+func TestRepository_ChooseRoom(t *testing.T) {
+	tests := []struct {
+		name             string
+		roomID           string
+		adults           int
+		children         int
+		expectedLocation string
+	}{
+		{"fits", "1", 1, 1, "/make-reservation"},
+		{"too small", "1", 2, 1, "/search-availability"},
+		{"unknown room", "0", 1, 0, "/search-availability"},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("GET", "/choose-room/"+e.roomID, nil)
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.roomID)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+		session.Put(ctx, "reservation", models.Reservation{Adults: e.adults, Children: e.children})
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.ChooseRoom)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("%s: ChooseRoom returned wrong status code: got %d, want %d", e.name, rr.Code, http.StatusSeeOther)
+		}
+		if rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %s, got %s", e.name, e.expectedLocation, rr.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRepository_ChooseRooms(t *testing.T) {
+	tests := []struct {
+		name             string
+		query            string
+		expectedCode     int
+		expectedLocation string
+		expectedRooms    int
+	}{
+		{"two rooms", "?room=1&room=2", http.StatusSeeOther, "/make-reservation", 2},
+		{"too small for the party", "?room=1", http.StatusSeeOther, "/search-availability", 0},
+		{"same room twice", "?room=2&room=2", http.StatusBadRequest, "", 0},
+		{"no rooms", "", http.StatusBadRequest, "", 0},
+		{"too many rooms", "?room=1&room=2&room=3&room=4", http.StatusBadRequest, "", 0},
+		{"not a room", "?room=x", http.StatusBadRequest, "", 0},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("GET", "/choose-rooms"+e.query, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		session.Put(ctx, "reservation", models.Reservation{Adults: 2, Children: 3})
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.ChooseRooms)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: ChooseRooms returned wrong status code: got %d, want %d", e.name, rr.Code, e.expectedCode)
+		}
+		if e.expectedLocation != "" && rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %s, got %s", e.name, e.expectedLocation, rr.Header().Get("Location"))
+		}
+		roomIDs, _ := session.Get(ctx, "room_ids").([]int)
+		if len(roomIDs) != e.expectedRooms {
+			t.Errorf("%s: expected %d rooms in the session, got %v", e.name, e.expectedRooms, roomIDs)
+		}
+	}
+}
+
+func TestRepository_PostReservation_Rooms(t *testing.T) {
+	postedData := url.Values{
+		"first_name": {"John"},
+		"last_name":  {"Smith"},
+		"email":      {"john@smith.com"},
+	}
+
+	tests := []struct {
+		name             string
+		roomIDs          []int
+		expectedLocation string
+		expectedRooms    int
+	}{
+		{"two rooms", []int{1, 1}, "/reservation-summary", 2},
+		{"one room taken", []int{1, 2}, "/search-availability", 0},
+	}
+
+	for _, e := range tests {
+		reservation := models.Reservation{
+			RoomID:    e.roomIDs[0],
+			StartDate: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2050, 1, 3, 0, 0, 0, 0, time.UTC),
+			Adults:    2,
+			Children:  1,
+		}
+
+		req, _ := http.NewRequest("POST", "/make-reservation", strings.NewReader(postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		session.Put(ctx, "reservation", reservation)
+		session.Put(ctx, "room_ids", e.roomIDs)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostReservation)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected a redirect to %s, got %d %s", e.name, e.expectedLocation, rr.Code, rr.Header().Get("Location"))
+		}
+
+		stays, _ := session.Get(ctx, "reservations").([]models.Reservation)
+		if len(stays) != e.expectedRooms {
+			t.Errorf("%s: expected %d reservations in the session, got %d", e.name, e.expectedRooms, len(stays))
+			continue
+		}
+		if len(stays) > 0 {
+			if stays[0].ConfirmationCode == stays[1].ConfirmationCode {
+				t.Errorf("%s: expected each room to have its own confirmation code", e.name)
+			}
+			if stays[0].Adults+stays[1].Adults != 2 || stays[0].Children+stays[1].Children != 1 {
+				t.Errorf("%s: expected the party to be shared out over the rooms, got %+v", e.name, stays)
+			}
+		}
+	}
+}
+
+func TestRepository_AvailabilityJSON(t *testing.T) {
+	tests := []struct {
+		name         string
+		start        string
+		end          string
+		roomID       string
+		expectedCode int
+		expectedOk   bool
+	}{
+		{"available", "2050-01-01", "2050-01-03", "1", http.StatusOK, true},
+		{"not available", "2050-01-01", "2050-01-03", "2", http.StatusOK, false},
+		{"departure before arrival", "2050-01-03", "2050-01-01", "1", http.StatusUnprocessableEntity, false},
+		{"missing room", "2050-01-01", "2050-01-03", "", http.StatusUnprocessableEntity, false},
+	}
+
+	for _, e := range tests {
+		postedData := url.Values{"start": {e.start}, "end": {e.end}, "room_id": {e.roomID}}
+		req, _ := http.NewRequest("POST", "/search-availability-json", strings.NewReader(postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(getCtx(req))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AvailabilityJSON)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: AvailabilityJSON returned wrong status code: got %d, want %d", e.name, rr.Code, e.expectedCode)
+		}
+
+		var resp jsonResponse
+		err := json.Unmarshal(rr.Body.Bytes(), &resp)
+		if err != nil {
+			t.Errorf("%s: cannot parse response: %s", e.name, err)
+			continue
+		}
+		if resp.Ok != e.expectedOk {
+			t.Errorf("%s: expected ok to be %v", e.name, e.expectedOk)
+		}
+		if rr.Code == http.StatusUnprocessableEntity && len(resp.Errors) == 0 {
+			t.Errorf("%s: expected field errors", e.name)
+		}
+	}
+}
+
+func TestRepository_RoomCalendarJSON(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expectedDays int
+	}{
+		{"january", "?room_id=1&month=2050-01", http.StatusOK, 31},
+		{"february", "?room_id=1&month=2050-02", http.StatusOK, 28},
+		{"unknown room", "?room_id=9&month=2050-01", http.StatusNotFound, 0},
+		{"missing room", "?month=2050-01", http.StatusUnprocessableEntity, 0},
+		{"bad month", "?room_id=1&month=2050-13", http.StatusUnprocessableEntity, 0},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("GET", "/room-calendar-json"+e.query, nil)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.RoomCalendarJSON)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: RoomCalendarJSON returned wrong status code: got %d, want %d", e.name, rr.Code, e.expectedCode)
+		}
+
+		var resp calendarResponse
+		err := json.Unmarshal(rr.Body.Bytes(), &resp)
+		if err != nil {
+			t.Errorf("%s: cannot parse response: %s", e.name, err)
+			continue
+		}
+		if len(resp.Days) != e.expectedDays {
+			t.Errorf("%s: expected %d days, got %d", e.name, e.expectedDays, len(resp.Days))
+		}
+		if e.expectedDays > 0 && (resp.Days[0].Date != e.query[len(e.query)-7:]+"-01" || !resp.Days[0].Available || resp.Days[4].Available) {
+			t.Errorf("%s: expected the month to start on the 1st with the 5th taken, got %+v", e.name, resp.Days[:5])
+		}
+	}
+}
+
+func TestRepository_RoomCalendarJSON_French(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/room-calendar-json?month=2050", nil)
+	fr, _ := i18n.Lookup("fr")
+	req = req.WithContext(i18n.NewContext(req.Context(), fr))
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.RoomCalendarJSON)
+	handler.ServeHTTP(rr, req)
+
+	var resp calendarResponse
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "Veuillez vérifier la chambre et le mois" {
+		t.Errorf("expected the message in French, got %q", resp.Message)
+	}
+	if got := resp.Errors["month"]; len(got) != 1 || got[0] != "Saisissez un mois au format AAAA-MM" {
+		t.Errorf("expected the month error in French, got %v", got)
+	}
+}
+
+func TestRepository_AdminReservationsCalendar(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/admin/reservation-calendar?y=2050&m=2", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminReservationsCalendar)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("AdminReservationsCalendar returned wrong status code: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	// the retired room keeps its history on the calendar, but its blocks cannot be changed
+	body := rr.Body.String()
+	for _, expected := range []string{"Old Wing", "Retired", `name="block_4_2050-02-06"`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected the calendar to contain %q", expected)
+		}
+	}
+	if !regexp.MustCompile(`name="block_4_2050-02-06"\s+checked\s+disabled`).MatchString(body) {
+		t.Error("expected the retired room's block to be shown read-only")
+	}
+	if regexp.MustCompile(`name="block_1_2050-02-06"\s+checked\s+disabled`).MatchString(body) {
+		t.Error("expected the blocks of rooms in use to stay editable")
+	}
+}
+
+func TestRepository_PostAdminReservationsCalendar(t *testing.T) {
+	// every room has an owner block on the 6th, including the retired Old Wing, whose boxes are disabled;
+	// unticking the 6th for the active rooms must leave the retired room's block alone
+	postedData := url.Values{"y": {"2050"}, "m": {"2"}}
+	req, _ := http.NewRequest("POST", "/admin/reservation-calendar", strings.NewReader(postedData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.PostAdminReservationsCalendar)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("PostAdminReservationsCalendar returned wrong status code: got %d, want %d", rr.Code, http.StatusSeeOther)
+	}
+	if rr.Header().Get("Location") != "/admin/reservation-calendar?y=2050&m=2" {
+		t.Errorf("expected a redirect back to the month, got %s", rr.Header().Get("Location"))
+	}
+	if session.GetString(ctx, "flash") == "" {
+		t.Error("expected a flash message")
+	}
+}
+
+func TestRepository_AdminPostBookingRules(t *testing.T) {
+	tests := []struct {
+		name          string
+		postedData    url.Values
+		expectedFlash string
+	}{
+		{"valid", url.Values{"min_nights": {"2"}, "max_nights": {"14"}, "no_arrival_days": {"0"}}, "flash"},
+		{"no rules", url.Values{}, "flash"},
+		{"negative", url.Values{"lead_time_hours": {"-1"}}, "error"},
+		{"not a number", url.Values{"min_nights": {"two"}}, "error"},
+		{"max below min", url.Values{"min_nights": {"7"}, "max_nights": {"3"}}, "error"},
+		{"unknown weekday", url.Values{"no_arrival_days": {"7"}}, "error"},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/admin/rooms/1/rules", strings.NewReader(e.postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostBookingRules)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/rooms/1" {
+			t.Errorf("%s: expected a redirect to the room, got %d %s", e.name, rr.Code, rr.Header().Get("Location"))
+		}
+		if session.GetString(ctx, e.expectedFlash) == "" {
+			t.Errorf("%s: expected a %s message", e.name, e.expectedFlash)
+		}
+	}
+}
+
+func TestRepository_AdminPostAPIKey(t *testing.T) {
+	tests := []struct {
+		name         string
+		postedData   url.Values
+		expectedCode int
+	}{
+		{"valid", url.Values{"name": {"Widget"}, "scope": {"read-availability"}}, http.StatusSeeOther},
+		{"no scope", url.Values{"name": {"Widget"}}, http.StatusOK},
+		{"unknown scope only", url.Values{"name": {"Widget"}, "scope": {"root"}}, http.StatusOK},
+		{"no name", url.Values{"scope": {"admin"}}, http.StatusOK},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/admin/api-keys", strings.NewReader(e.postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostAPIKey)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: AdminPostAPIKey returned wrong status code: got %d, want %d", e.name, rr.Code, e.expectedCode)
+		}
+
+		key := session.GetString(ctx, "new_api_key")
+		if (e.expectedCode == http.StatusSeeOther) != strings.HasPrefix(key, "bk_") {
+			t.Errorf("%s: unexpected new key in session: %q", e.name, key)
+		}
+	}
+}
+
+func TestCanCancel(t *testing.T) {
+	now := time.Date(2050, 1, 1, 12, 0, 0, 0, time.UTC)
+	res := models.Reservation{StartDate: time.Date(2050, 1, 4, 0, 0, 0, 0, time.UTC)}
+
+	if !canCancel(res, 48*time.Hour, now) {
+		t.Error("expected a reservation two and a half days out to be cancellable with a 48 hour window")
+	}
+	if canCancel(res, 72*time.Hour, now) {
+		t.Error("expected a reservation two and a half days out not to be cancellable with a 72 hour window")
+	}
+
+	res.CancelledAt = now
+	if canCancel(res, 48*time.Hour, now) {
+		t.Error("expected a cancelled reservation not to be cancellable again")
+	}
+}
+
 func getCtx(req *http.Request) context.Context {
 	ctx, err := session.Load(req.Context(), req.Header.Get("X-Session"))
 	if err != nil {
@@ -183,3 +1418,111 @@ func getCtx(req *http.Request) context.Context {
 	}
 	return ctx
 }
+
+func TestReservationFilterFromQuery(t *testing.T) {
+	q := url.Values{}
+	q.Set("page", "3")
+	q.Set("sort", "room")
+	q.Set("dir", "desc")
+	q.Set("from", "2050-01-01")
+	q.Set("to", "not-a-date")
+	q.Set("room_id", "2")
+
+	f := reservationFilterFromQuery(q)
+	if f.Page != 3 || f.SortBy != "room" || !f.SortDesc || f.RoomID != 2 {
+		t.Errorf("unexpected filter: %+v", f)
+	}
+	if f.From.Format("2006-01-02") != "2050-01-01" {
+		t.Errorf("expected from date 2050-01-01, got %s", f.From)
+	}
+	if !f.To.IsZero() {
+		t.Error("invalid to date should be ignored")
+	}
+
+	q.Set("sort", "password")
+	q.Set("page", "-1")
+	f = reservationFilterFromQuery(q)
+	if f.SortBy != "start_date" || f.Page != 1 {
+		t.Errorf("unknown sort and bad page should fall back to defaults, got %+v", f)
+	}
+
+	link := allReservationsURL(f)
+	parsed, _ := url.Parse(link)
+	if reservationFilterFromQuery(parsed.Query()) != f {
+		t.Errorf("link %s does not round trip the filter", link)
+	}
+}
+
+func TestCalendarMonth(t *testing.T) {
+	tests := []struct {
+		y, m     string
+		expected string
+	}{
+		{"2050", "2", "2050-02-01"},
+		{"2050", "12", "2050-12-01"},
+		{"2050", "13", time.Now().Format("2006-01") + "-01"},
+		{"", "", time.Now().Format("2006-01") + "-01"},
+	}
+
+	for _, e := range tests {
+		if got := calendarMonth(e.y, e.m).Format("2006-01-02"); got != e.expected {
+			t.Errorf("calendarMonth(%q, %q) = %s, want %s", e.y, e.m, got, e.expected)
+		}
+	}
+}
+
+func TestReservationListURL(t *testing.T) {
+	q := url.Values{}
+	q.Set("y", "2050")
+	q.Set("m", "2")
+
+	tests := map[string]string{
+		"new": "/admin/reservations-new",
+		"all": "/admin/reservations-all",
+		"cal": "/admin/reservation-calendar?y=2050&m=2",
+		"":    "/admin/reservations-all",
+	}
+
+	for src, expected := range tests {
+		if got := reservationListURL(src, q); got != expected {
+			t.Errorf("reservationListURL(%q) = %s, want %s", src, got, expected)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Major's Suite":         "majors-suite",
+		"  General’s Quarters ": "generals-quarters",
+		"Room #3 -- Garden!":    "room-3-garden",
+		"":                      "",
+	}
+
+	for in, expected := range tests {
+		if got := slugify(in); got != expected {
+			t.Errorf("slugify(%q) = %q, want %q", in, got, expected)
+		}
+	}
+}
+
+func TestParseCurrency(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected int
+		ok       bool
+	}{
+		{"129", 12900, true},
+		{"$129.5", 12950, true},
+		{"129.05", 12905, true},
+		{"129.055", 0, false},
+		{"-5", 0, false},
+		{"abc", 0, false},
+	}
+
+	for _, e := range tests {
+		got, ok := parseCurrency(e.in)
+		if got != e.expected || ok != e.ok {
+			t.Errorf("parseCurrency(%q) = %d, %t, want %d, %t", e.in, got, ok, e.expected, e.ok)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"encoding/json"
+	"learn-golang/internal/models"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiParam is a query or path parameter of a documented endpoint
+type apiParam struct {
+	Name        string
+	In          string
+	Type        string
+	Required    bool
+	Description string
+}
+
+// apiOperation documents one JSON endpoint; request and response schemas are generated from the
+// Go types the handler actually decodes and encodes, so they cannot drift from the code
+type apiOperation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Scope       string
+	Params      []apiParam
+	Request     any
+	Response    any
+	Status      int
+	Errors      []int
+	FormRequest []apiParam
+	Raw         bool
+}
+
+// APIOperations is every JSON endpoint the site serves, as published in the OpenAPI document
+var APIOperations = []apiOperation{
+	{
+		Method:   http.MethodGet,
+		Path:     "/api/v1/rooms",
+		Summary:  "List the rooms that can be booked",
+		Scope:    models.ScopeReadAvailability,
+		Response: []apiRoom{},
+		Status:   http.StatusOK,
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/availability",
+		Summary: "Check which rooms are free for a stay, with the price of the stay for free rooms",
+		Scope:   models.ScopeReadAvailability,
+		Params: []apiParam{
+			{Name: "start", In: "query", Type: "date", Required: true, Description: "Arrival date"},
+			{Name: "end", In: "query", Type: "date", Required: true, Description: "Departure date"},
+			{Name: "room_id", In: "query", Type: "integer", Description: "Only check this room"},
+		},
+		Response: []apiAvailability{},
+		Status:   http.StatusOK,
+		Errors:   []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	{
+		Method:   http.MethodPost,
+		Path:     "/api/v1/reservations",
+		Summary:  "Book a room",
+		Scope:    models.ScopeWriteReservations,
+		Request:  apiReservationRequest{},
+		Response: apiReservation{},
+		Status:   http.StatusCreated,
+		Errors:   []int{http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/api/v1/reservations/{code}",
+		Summary: "Look up a reservation by confirmation code; keys without the admin scope must also give the guest's email",
+		Scope:   models.ScopeReadReservations,
+		Params: []apiParam{
+			{Name: "code", In: "path", Type: "string", Required: true, Description: "Confirmation code"},
+			{Name: "email", In: "query", Type: "string", Description: "Email address the reservation was made with"},
+		},
+		Response: apiReservation{},
+		Status:   http.StatusOK,
+		Errors:   []int{http.StatusNotFound},
+	},
+	{
+		Method:  http.MethodPost,
+		Path:    "/search-availability-json",
+		Summary: "Check whether one room is free, as used by the room pages; needs the CSRF token of a page session",
+		FormRequest: []apiParam{
+			{Name: "csrf_token", Type: "string", Required: true},
+			{Name: "start", Type: "date", Required: true},
+			{Name: "end", Type: "date", Required: true},
+			{Name: "room_id", Type: "integer", Required: true},
+		},
+		Response: jsonResponse{},
+		Status:   http.StatusOK,
+		Errors:   []int{http.StatusUnprocessableEntity},
+		Raw:      true,
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/room-calendar-json",
+		Summary: "List whether a room is free for each night of a month, as used by the room page datepicker",
+		Params: []apiParam{
+			{Name: "room_id", In: "query", Type: "integer", Required: true, Description: "Room to check"},
+			{Name: "month", In: "query", Type: "string", Required: true, Description: "Month as YYYY-MM"},
+		},
+		Response: calendarResponse{},
+		Status:   http.StatusOK,
+		Errors:   []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+		Raw:      true,
+	},
+}
+
+// OpenAPI serves the OpenAPI 3 document describing the JSON endpoints
+func OpenAPI(w http.ResponseWriter, _ *http.Request) {
+	out, err := json.MarshalIndent(openAPIDocument(), "", "  ")
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal_error", "Something went wrong on our side")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
+
+// openAPIDocument builds the OpenAPI document from APIOperations
+func openAPIDocument() map[string]any {
+	schemas := map[string]any{}
+	paths := map[string]any{}
+
+	schemas["Error"] = schemaFor(reflect.TypeOf(apiError{}), schemas)
+	schemas["ErrorEnvelope"] = map[string]any{
+		"type":       "object",
+		"required":   []string{"error"},
+		"properties": map[string]any{"error": ref("Error")},
+	}
+
+	for _, op := range APIOperations {
+		operation := map[string]any{
+			"summary":     op.Summary,
+			"operationId": operationID(op),
+		}
+
+		if op.Scope != "" {
+			operation["security"] = []any{map[string]any{"apiKey": []string{}}}
+			operation["x-required-scope"] = op.Scope
+			operation["description"] = "Requires an API key with the " + op.Scope + " scope, or the admin scope."
+		}
+
+		var params []any
+		for _, p := range op.Params {
+			params = append(params, map[string]any{
+				"name":        p.Name,
+				"in":          p.In,
+				"required":    p.Required,
+				"description": p.Description,
+				"schema":      paramSchema(p.Type),
+			})
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		if op.Request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": schemaFor(reflect.TypeOf(op.Request), schemas)},
+				},
+			}
+		}
+		if len(op.FormRequest) > 0 {
+			props := map[string]any{}
+			var required []string
+			for _, p := range op.FormRequest {
+				props[p.Name] = paramSchema(p.Type)
+				if p.Required {
+					required = append(required, p.Name)
+				}
+			}
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/x-www-form-urlencoded": map[string]any{
+						"schema": map[string]any{"type": "object", "properties": props, "required": required},
+					},
+				},
+			}
+		}
+
+		body := schemaFor(reflect.TypeOf(op.Response), schemas)
+		if !op.Raw {
+			body = map[string]any{
+				"type":       "object",
+				"required":   []string{"data"},
+				"properties": map[string]any{"data": body},
+			}
+		}
+		responses := map[string]any{
+			strconv.Itoa(op.Status): map[string]any{
+				"description": http.StatusText(op.Status),
+				"content":     map[string]any{"application/json": map[string]any{"schema": body}},
+			},
+		}
+		errs := op.Errors
+		if op.Scope != "" {
+			errs = append(errs, http.StatusUnauthorized, http.StatusForbidden)
+		}
+		// raw endpoints answer errors in the same shape as successes
+		errSchema := ref("ErrorEnvelope")
+		if op.Raw {
+			errSchema = schemaFor(reflect.TypeOf(op.Response), schemas)
+		}
+		for _, status := range errs {
+			responses[strconv.Itoa(status)] = map[string]any{
+				"description": http.StatusText(status),
+				"content":     map[string]any{"application/json": map[string]any{"schema": errSchema}},
+			}
+		}
+		operation["responses"] = responses
+
+		item, ok := paths[op.Path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Bookings API",
+			"version": "1.0.0",
+			"description": "Rooms, availability and reservations. Prices are in cents. " +
+				"Errors are returned as {\"error\": {...}} and data as {\"data\": ...}.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{"type": "http", "scheme": "bearer", "description": "API key issued in the admin tool"},
+			},
+		},
+	}
+}
+
+// operationID names an operation after its method and path, e.g. get_api_v1_rooms
+func operationID(op apiOperation) string {
+	r := strings.NewReplacer("/", "_", "{", "", "}", "", "-", "_")
+	return strings.ToLower(op.Method) + r.Replace(op.Path)
+}
+
+// ref returns a reference to a component schema
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// paramSchema returns the schema of a parameter of the given type
+func paramSchema(typ string) map[string]any {
+	if typ == "date" {
+		return map[string]any{"type": "string", "format": "date"}
+	}
+	return map[string]any{"type": typ}
+}
+
+// schemaName returns the component name of a struct type, e.g. Reservation for apiReservation
+func schemaName(t reflect.Type) string {
+	name := strings.TrimPrefix(t.Name(), "api")
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// schemaFor returns the schema of a Go type as it is encoded by encoding/json,
+// adding struct types to schemas as components and referring to them
+func schemaFor(t reflect.Type, schemas map[string]any) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := schemaFor(t.Elem(), schemas)
+		// siblings of $ref are ignored, so a nullable reference has to go through allOf
+		if _, isRef := s["$ref"]; isRef {
+			return map[string]any{"allOf": []any{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, done := schemas[name]; done {
+			return ref(name)
+		}
+		// reserve the name first in case the type refers to itself
+		schemas[name] = map[string]any{}
+
+		props := map[string]any{}
+		var required []string
+		for _, f := range jsonFields(t) {
+			props[f.name] = schemaFor(f.typ, schemas)
+			if !f.omitempty {
+				required = append(required, f.name)
+			}
+		}
+		sort.Strings(required)
+
+		schema := map[string]any{"type": "object", "properties": props}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		schemas[name] = schema
+		return ref(name)
+	}
+
+	return map[string]any{}
+}
+
+// jsonField is a struct field as seen by encoding/json
+type jsonField struct {
+	name      string
+	typ       reflect.Type
+	omitempty bool
+}
+
+// jsonFields returns the exported fields of a struct type under their JSON names
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{name: name, typ: f.Type, omitempty: strings.Contains(opts, "omitempty")})
+	}
+	return fields
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// openAPISamples are requests that exercise every documented operation; each response is checked
+// against the schema the document gives for its status code
+var openAPISamples = []struct {
+	operation string
+	method    string
+	url       string
+	body      string
+	form      url.Values
+	status    int
+}{
+	{"GET /api/v1/rooms", "GET", "/api/v1/rooms", "", nil, http.StatusOK},
+	{"GET /api/v1/availability", "GET", "/api/v1/availability?start=2050-01-01&end=2050-01-03", "", nil, http.StatusOK},
+	{"GET /api/v1/availability", "GET", "/api/v1/availability?start=2050-01-01", "", nil, http.StatusUnprocessableEntity},
+	{
+		"POST /api/v1/reservations", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","room_id":1,"start_date":"2050-01-01","end_date":"2050-01-03"}`,
+		nil, http.StatusCreated,
+	},
+	{"POST /api/v1/reservations", "POST", "/api/v1/reservations", `{"first_name":"J"}`, nil, http.StatusUnprocessableEntity},
+	{"GET /api/v1/reservations/{code}", "GET", "/api/v1/reservations/GONE2345", "", nil, http.StatusOK},
+	{"GET /api/v1/reservations/{code}", "GET", "/api/v1/reservations/ZZZZ2345", "", nil, http.StatusNotFound},
+	{
+		"POST /search-availability-json", "POST", "/search-availability-json", "",
+		url.Values{"start": {"2050-01-01"}, "end": {"2050-01-03"}, "room_id": {"1"}}, http.StatusOK,
+	},
+	{
+		"POST /search-availability-json", "POST", "/search-availability-json", "",
+		url.Values{"start": {"2050-01-03"}, "end": {"2050-01-01"}, "room_id": {"1"}}, http.StatusUnprocessableEntity,
+	},
+	{"GET /room-calendar-json", "GET", "/room-calendar-json?room_id=1&month=2050-01", "", nil, http.StatusOK},
+	{"GET /room-calendar-json", "GET", "/room-calendar-json?room_id=9&month=2050-01", "", nil, http.StatusNotFound},
+	{"GET /room-calendar-json", "GET", "/room-calendar-json?room_id=1&month=soon", "", nil, http.StatusUnprocessableEntity},
+}
+
+func TestOpenAPI(t *testing.T) {
+	rr := httptest.NewRecorder()
+	OpenAPI(rr, httptest.NewRequest("GET", "/api/openapi.json", nil))
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected a JSON document, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	var doc map[string]any
+	err := json.Unmarshal(rr.Body.Bytes(), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["openapi"] != "3.0.3" {
+		t.Errorf("expected an OpenAPI 3 document, got version %v", doc["openapi"])
+	}
+
+	paths := doc["paths"].(map[string]any)
+	for _, op := range APIOperations {
+		if _, ok := paths[op.Path].(map[string]any)[strings.ToLower(op.Method)]; !ok {
+			t.Errorf("%s %s is missing from the document", op.Method, op.Path)
+		}
+	}
+}
+
+func TestOpenAPI_ResponsesMatchHandlers(t *testing.T) {
+	var doc map[string]any
+	out, _ := json.Marshal(openAPIDocument())
+	_ = json.Unmarshal(out, &doc)
+
+	routes := getRoutes()
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	sampled := make(map[string]bool)
+	for _, e := range openAPISamples {
+		sampled[e.operation] = true
+
+		method, path, _ := strings.Cut(e.operation, " ")
+		op, ok := doc["paths"].(map[string]any)[path].(map[string]any)[strings.ToLower(method)].(map[string]any)
+		if !ok {
+			t.Errorf("%s: not in the document", e.operation)
+			continue
+		}
+		documented, ok := op["responses"].(map[string]any)[strconv.Itoa(e.status)].(map[string]any)
+		if !ok {
+			t.Errorf("%s: status %d is not documented", e.operation, e.status)
+			continue
+		}
+		schema := documented["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+
+		var body *strings.Reader
+		if e.form != nil {
+			body = strings.NewReader(e.form.Encode())
+		} else {
+			body = strings.NewReader(e.body)
+		}
+		req, _ := http.NewRequest(e.method, ts.URL+e.url, body)
+		req.Header.Set("Authorization", "Bearer bk_test_admin")
+		if e.form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got any
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Errorf("%s: response is not JSON: %s", e.operation, err)
+			continue
+		}
+
+		if resp.StatusCode != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.operation, e.status, resp.StatusCode)
+			continue
+		}
+
+		for _, problem := range checkSchema(doc, schema, got, "response") {
+			t.Errorf("%s %d: %s", e.operation, e.status, problem)
+		}
+	}
+
+	for _, op := range APIOperations {
+		if !sampled[op.Method+" "+op.Path] {
+			t.Errorf("%s %s has no sample request in openAPISamples", op.Method, op.Path)
+		}
+	}
+
+	// every JSON route served has to be documented, so a new endpoint cannot slip past the document
+	documented := make(map[string]bool)
+	for _, op := range APIOperations {
+		documented[op.Method+" "+op.Path] = true
+	}
+	err := chi.Walk(
+		routes.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			isJSON := strings.HasPrefix(route, "/api/") || strings.HasSuffix(route, "-json")
+			if isJSON && route != "/api/openapi.json" && !documented[method+" "+route] {
+				t.Errorf("%s %s is served but missing from APIOperations", method, route)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkSchema returns every way in which value does not match schema, resolving references in doc
+func checkSchema(doc map[string]any, schema map[string]any, value any, at string) []string {
+	if all, ok := schema["allOf"].([]any); ok {
+		if value == nil && schema["nullable"] == true {
+			return nil
+		}
+		var problems []string
+		for _, sub := range all {
+			problems = append(problems, checkSchema(doc, sub.(map[string]any), value, at)...)
+		}
+		return problems
+	}
+
+	if r, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(r, "#/components/schemas/")
+		schema = doc["components"].(map[string]any)["schemas"].(map[string]any)[name].(map[string]any)
+	}
+
+	if value == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s is null", at)}
+	}
+
+	var problems []string
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s is %T, not an object", at, value)}
+		}
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s is required but missing", at, name))
+			}
+		}
+		props, hasProps := schema["properties"].(map[string]any)
+		extra, _ := schema["additionalProperties"].(map[string]any)
+		for name, v := range obj {
+			switch {
+			case hasProps && props[name] != nil:
+				problems = append(problems, checkSchema(doc, props[name].(map[string]any), v, at+"."+name)...)
+			case extra != nil:
+				problems = append(problems, checkSchema(doc, extra, v, at+"."+name)...)
+			default:
+				problems = append(problems, fmt.Sprintf("%s.%s is not documented", at, name))
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s is %T, not an array", at, value)}
+		}
+		for i, v := range arr {
+			problems = append(problems, checkSchema(doc, schema["items"].(map[string]any), v, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			problems = append(problems, fmt.Sprintf("%s is %T, not a string", at, value))
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			problems = append(problems, fmt.Sprintf("%s is %v, not an integer", at, value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			problems = append(problems, fmt.Sprintf("%s is %T, not a boolean", at, value))
+		}
+	}
+
+	return problems
+}
+
+func TestCheckSchema(t *testing.T) {
+	var doc map[string]any
+	out, _ := json.Marshal(openAPIDocument())
+	_ = json.Unmarshal(out, &doc)
+
+	room := map[string]any{"$ref": "#/components/schemas/Room"}
+
+	var good, bad any
+	_ = json.Unmarshal([]byte(`{"id":1,"slug":"a","name":"A","description":"","capacity":2,"nightly_rate":100,
+		"weekend_surcharge":0,"photos":[]}`), &good)
+	_ = json.Unmarshal([]byte(`{"id":"1","slug":"a","name":"A","description":"","capacity":2,"nightly_rate":100,
+		"photos":[],"colour":"red"}`), &bad)
+
+	if problems := checkSchema(doc, room, good, "room"); len(problems) > 0 {
+		t.Errorf("expected a valid room to match, got %v", problems)
+	}
+	if problems := checkSchema(doc, room, bad, "room"); len(problems) != 3 {
+		t.Errorf("expected a wrong type, a missing field and an undocumented field, got %v", problems)
+	}
+}
+
+func TestSchemaFor_NullableStruct(t *testing.T) {
+	type apiNested struct {
+		Name string `json:"name"`
+	}
+	type apiOuter struct {
+		Nested *apiNested `json:"nested"`
+	}
+
+	schemas := map[string]any{}
+	schemaFor(reflect.TypeOf(apiOuter{}), schemas)
+
+	nested := schemas["Outer"].(map[string]any)["properties"].(map[string]any)["nested"].(map[string]any)
+	if _, ok := nested["$ref"]; ok {
+		t.Errorf("expected the reference wrapped so nullable is not ignored, got %v", nested)
+	}
+	all, ok := nested["allOf"].([]any)
+	if !ok || len(all) != 1 || nested["nullable"] != true {
+		t.Fatalf("expected a nullable allOf with one reference, got %v", nested)
+	}
+	if all[0].(map[string]any)["$ref"] != "#/components/schemas/Nested" {
+		t.Errorf("expected a reference to Nested, got %v", all[0])
+	}
+}
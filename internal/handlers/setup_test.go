@@ -9,27 +9,48 @@ import (
 	"github.com/justinas/nosurf"
 	"html/template"
 	"learn-golang/internal/config"
+	"learn-golang/internal/helpers"
+	"learn-golang/internal/i18n"
+	"learn-golang/internal/mailer"
 	"learn-golang/internal/models"
 	"learn-golang/internal/render"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	ttemplate "text/template"
 	"time"
 )
 
 var testApp config.AppConfig
 var session *scs.SessionManager
+var sentMail = &mailer.Recorder{}
 var pathToTemplates = "./../../templates"
-var functions = template.FuncMap{}
+var pathToEmailTemplates = "./../../email-templates"
+var functions = template.FuncMap{
+	"humanDate":      render.HumanDate,
+	"formatCurrency": render.FormatCurrency,
+	"lang":           func() string { return i18n.Default.Tag },
+	"t":              i18n.Default.T,
+	"date":           i18n.Default.FormatDate,
+	"dateTime":       i18n.Default.FormatDateTime,
+}
 
 func TestMain(m *testing.M) {
 	// what am I going to put in the session
 	gob.Register(models.Reservation{})
+	gob.Register([]models.Reservation{})
 
 	// change this to true when in production
 	testApp.InProduction = false
+	testApp.CancellationWindow = 48 * time.Hour
+	testApp.BaseURL = "http://localhost:8080"
+	testApp.Address = "1 Fort Road, Smythe"
+	testApp.MailFrom = "bookings@fortsmythe.test"
+	testApp.LinkKey = []byte("test signing key")
+	testApp.LinkTTL = time.Hour
 
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	testApp.InfoLog = infoLog
@@ -45,6 +66,7 @@ func TestMain(m *testing.M) {
 	session.Cookie.Secure = testApp.InProduction
 
 	testApp.Session = session
+	testApp.Mailer = sentMail
 
 	templateCache, err := CreateTestTemplateCache()
 	if err != nil {
@@ -52,40 +74,115 @@ func TestMain(m *testing.M) {
 	}
 
 	testApp.TemplateCache = templateCache
+
+	emailCache, emailTextCache, err := CreateTestEmailTemplateCache()
+	if err != nil {
+		log.Fatal("cannot create email template cache")
+	}
+
+	testApp.EmailCache = emailCache
+	testApp.EmailTextCache = emailTextCache
 	testApp.UseCache = true
 
 	repo := NewTestRepo(&testApp)
 	NewHandlers(repo)
 	render.NewRenderer(&testApp)
+	helpers.NewHelpers(&testApp)
 
-	os.Exit(m.Run())
+	code := m.Run()
+	os.Exit(code)
 }
 
 func getRoutes() http.Handler {
 	mux := chi.NewRouter()
 
 	mux.Use(middleware.Recoverer)
-	// mux.Use(NoSurf)
-	_ = NoSurf(nil)
-	mux.Use(SessionLoad)
+	mux.Use(Locale)
+
+	// the JSON API is used by scripts and partners rather than browsers, so it has no CSRF check or session
+	mux.Get("/api/openapi.json", OpenAPI)
+	mux.Route(
+		"/api/v1", func(mux chi.Router) {
+			mux.NotFound(APINotFound)
+			mux.MethodNotAllowed(APIMethodNotAllowed)
+			mux.With(Repo.RequireAPIKey(models.ScopeReadAvailability)).Get("/rooms", Repo.APIRooms)
+			mux.With(Repo.RequireAPIKey(models.ScopeReadAvailability)).Get("/availability", Repo.APIAvailability)
+			mux.With(Repo.RequireAPIKey(models.ScopeWriteReservations)).Post("/reservations", Repo.APIPostReservation)
+			mux.With(Repo.RequireAPIKey(models.ScopeReadReservations)).Get("/reservations/{code}", Repo.APIReservation)
+		},
+	)
+
+	mux.Group(
+		func(mux chi.Router) {
+			// mux.Use(NoSurf)
+			_ = NoSurf(nil)
+			mux.Use(SessionLoad)
+
+			mux.Get("/", Repo.Home)
+			mux.Get("/about", Repo.About)
+			mux.Handle("/generals-quarters", http.RedirectHandler("/rooms/generals-quarters", http.StatusMovedPermanently))
+			mux.Handle("/majors-suite", http.RedirectHandler("/rooms/majors-suite", http.StatusMovedPermanently))
+			mux.Get("/rooms", Repo.Rooms)
+			mux.Get("/rooms/{slug}", Repo.Room)
+
+			mux.Get("/search-availability", Repo.Availability)
+			mux.Post("/search-availability", Repo.PostAvailability)
+			mux.Post("/search-availability-json", Repo.AvailabilityJSON)
+			mux.Get("/room-calendar-json", Repo.RoomCalendarJSON)
 
-	mux.Get("/", Repo.Home)
-	mux.Get("/about", Repo.About)
-	mux.Get("/generals-quarters", Repo.Generals)
-	mux.Get("/majors-suite", Repo.Majors)
+			mux.Get("/contact", Repo.Contact)
+			mux.Get("/language", Repo.Language)
 
-	mux.Get("/search-availability", Repo.Availability)
-	mux.Post("/search-availability", Repo.PostAvailability)
-	mux.Post("/search-availability-json", Repo.AvailabilityJSON)
+			mux.Get("/make-reservation", Repo.Reservation)
+			mux.Post("/make-reservation", Repo.PostReservation)
+			mux.Get("/reservation-summary", Repo.ReservationSummary)
 
-	mux.Get("/contact", Repo.Contact)
+			mux.Get("/reservation-lookup", Repo.ReservationLookup)
+			mux.Post("/reservation-lookup", Repo.PostReservationLookup)
+			mux.Get("/my-reservation", Repo.MyReservation)
+			mux.Get("/my-reservation/change", Repo.ChangeReservation)
+			mux.Post("/my-reservation/change", Repo.PostChangeReservation)
+			mux.Post("/my-reservation/cancel", Repo.PostCancelReservation)
+			mux.Get("/manage/{token}", Repo.ManageReservation)
 
-	mux.Get("/make-reservation", Repo.Reservation)
-	mux.Post("/make-reservation", Repo.PostReservation)
-	mux.Get("/reservation-summary", Repo.ReservationSummary)
+			fileServer := http.FileServer(http.Dir("./static/"))
+			mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
 
-	fileServer := http.FileServer(http.Dir("./static/"))
-	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
+			mux.Route(
+				"/admin", func(mux chi.Router) {
+					mux.Get("/dashboard", Repo.AdminDashboard)
+					mux.Get("/reservations-new", Repo.AdminNewReservations)
+					mux.Post("/reservations-new/processed", Repo.AdminProcessReservations)
+					mux.Get("/reservations-all", Repo.AdminAllReservations)
+					mux.Get("/reservation-calendar", Repo.AdminReservationsCalendar)
+					mux.Post("/reservation-calendar", Repo.PostAdminReservationsCalendar)
+
+					mux.Get("/reservations/{src}/{id}", Repo.AdminShowReservation)
+					mux.Post("/reservations/{src}/{id}", Repo.AdminPostShowReservation)
+					mux.Post("/reservations/{src}/{id}/delete", Repo.AdminDeleteReservation)
+					mux.Post("/reservations/{src}/{id}/processed", Repo.AdminProcessReservation)
+
+					mux.Get("/rooms", Repo.AdminRooms)
+					mux.Get("/rooms/new", Repo.AdminNewRoom)
+					mux.Post("/rooms/new", Repo.AdminPostRoom)
+					mux.Get("/rooms/{id}", Repo.AdminShowRoom)
+					mux.Post("/rooms/{id}", Repo.AdminPostRoom)
+					mux.Post("/rooms/{id}/delete", Repo.AdminDeleteRoom)
+					mux.Post("/rooms/{id}/seasons", Repo.AdminPostSeasonalRate)
+					mux.Post("/rooms/{id}/seasons/{seasonID}/delete", Repo.AdminDeleteSeasonalRate)
+					mux.Post("/rooms/{id}/rules", Repo.AdminPostBookingRules)
+
+					mux.Get("/api-keys", Repo.AdminAPIKeys)
+					mux.Post("/api-keys", Repo.AdminPostAPIKey)
+					mux.Post("/api-keys/{id}/revoke", Repo.AdminRevokeAPIKey)
+
+					mux.Get("/outbox", Repo.AdminOutbox)
+					mux.Get("/outbox/{id}", Repo.AdminShowOutboxMessage)
+					mux.Post("/outbox/{id}/resend", Repo.AdminResendOutboxMessage)
+				},
+			)
+		},
+	)
 
 	return mux
 }
@@ -104,6 +201,24 @@ func NoSurf(next http.Handler) http.Handler {
 	return csrfHandler
 }
 
+// Locale puts the language the request is answered in, picked by i18n.Negotiate, in its context
+func Locale(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var chosen string
+			if c, err := r.Cookie(i18n.CookieName); err == nil {
+				chosen = c.Value
+			}
+
+			l := i18n.Negotiate(chosen, r.Header.Get("Accept-Language"))
+			w.Header().Set("Content-Language", l.Tag)
+			w.Header().Add("Vary", "Accept-Language, Cookie")
+
+			next.ServeHTTP(w, r.WithContext(i18n.NewContext(r.Context(), l)))
+		},
+	)
+}
+
 // SessionLoad loads and saves the session on every request
 func SessionLoad(next http.Handler) http.Handler {
 	return session.LoadAndSave(next)
@@ -144,3 +259,42 @@ func CreateTestTemplateCache() (map[string]*template.Template, error) {
 
 	return cache, nil
 }
+
+// CreateTestEmailTemplateCache creates the html and plain text email template caches as maps
+func CreateTestEmailTemplateCache() (map[string]*template.Template, map[string]*ttemplate.Template, error) {
+	htmlCache := map[string]*template.Template{}
+	textCache := map[string]*ttemplate.Template{}
+
+	pages, err := filepath.Glob(fmt.Sprintf("%s/*.page.html.tmpl", pathToEmailTemplates))
+	if err != nil {
+		return htmlCache, textCache, err
+	}
+
+	for _, page := range pages {
+		name := strings.TrimSuffix(filepath.Base(page), ".page.html.tmpl")
+
+		ht, err := template.New(filepath.Base(page)).Funcs(functions).ParseFiles(page)
+		if err != nil {
+			return htmlCache, textCache, err
+		}
+		ht, err = ht.ParseGlob(fmt.Sprintf("%s/*.layout.html.tmpl", pathToEmailTemplates))
+		if err != nil {
+			return htmlCache, textCache, err
+		}
+
+		textPage := fmt.Sprintf("%s/%s.page.txt.tmpl", pathToEmailTemplates, name)
+		tt, err := ttemplate.New(filepath.Base(textPage)).Funcs(ttemplate.FuncMap(functions)).ParseFiles(textPage)
+		if err != nil {
+			return htmlCache, textCache, err
+		}
+		tt, err = tt.ParseGlob(fmt.Sprintf("%s/*.layout.txt.tmpl", pathToEmailTemplates))
+		if err != nil {
+			return htmlCache, textCache, err
+		}
+
+		htmlCache[name] = ht
+		textCache[name] = tt
+	}
+
+	return htmlCache, textCache, nil
+}
@@ -1,6 +1,7 @@
 package helpers
 
 import (
+	"crypto/rand"
 	"fmt"
 	"learn-golang/internal/config"
 	"net/http"
@@ -29,3 +30,24 @@ func IsAuthenticated(r *http.Request) bool {
 	exists := app.Session.Exists(r.Context(), "user_id")
 	return exists
 }
+
+// confirmationAlphabet leaves out characters that are easily misread, such as 0/O and 1/I
+const confirmationAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// confirmationCodeLength is the number of characters in a confirmation code
+const confirmationCodeLength = 8
+
+// NewConfirmationCode returns a random code a guest can quote to find their reservation
+func NewConfirmationCode() (string, error) {
+	b := make([]byte, confirmationCodeLength)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = confirmationAlphabet[int(b[i])%len(confirmationAlphabet)]
+	}
+
+	return string(b), nil
+}
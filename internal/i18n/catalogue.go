@@ -0,0 +1,264 @@
+package i18n
+
+// The catalogues map the English messages of the guest pages, their form errors, the guest emails and their
+// calendar invitations to their translations. A message missing here is shown in English.
+
+var french = map[string]string{
+	// navigation
+	"Home":           "Accueil",
+	"About":          "À propos",
+	"Rooms":          "Chambres",
+	"Book Now":       "Réserver",
+	"My Reservation": "Ma réservation",
+	"Contact":        "Contact",
+	"Login":          "Connexion",
+	"Language":       "Langue",
+	"Change":         "Changer",
+
+	// pages
+	"Welcome to Fort Smythe Bed and Breakfast": "Bienvenue au Bed and Breakfast de Fort Smythe",
+	"Make Reservation Now":                     "Réserver maintenant",
+	"Our Rooms":                                "Nos chambres",
+	"Sleeps %d":                                "%d couchages",
+	"sleeps %d":                                "%d couchages",
+	"sleeps %d together":                       "%d couchages ensemble",
+	"from %s per night":                        "à partir de %s la nuit",
+	"View room":                                "Voir la chambre",
+	"Check Availability":                       "Vérifier les disponibilités",
+	"Search for Availability":                  "Rechercher des disponibilités",
+	"Search Availability":                      "Rechercher",
+	"Arrival":                                  "Arrivée",
+	"Departure":                                "Départ",
+	"Adults":                                   "Adultes",
+	"Children":                                 "Enfants",
+	"Choose a Room":                            "Choisissez une chambre",
+	"Make Reservation":                         "Réserver",
+	"Reservation details":                      "Détails de la réservation",
+	"Reservation":                              "Réservation",
+	"Room:":                                    "Chambre :",
+	"Arrival:":                                 "Arrivée :",
+	"Departure:":                               "Départ :",
+	"Guests:":                                  "Voyageurs :",
+	"%d adult(s)":                              "%d adulte(s)",
+	"%d child(ren)":                            "%d enfant(s)",
+	"Total:":                                   "Total :",
+	"%s for %d night(s)":                       "%s pour %d nuit(s)",
+	"%s to %s":                                 "du %s au %s",
+	"%s weekend":                               "%s week-end",
+	"First Name:":                              "Prénom :",
+	"Last Name:":                               "Nom :",
+	"Name:":                                    "Nom :",
+	"Email:":                                   "E-mail :",
+	"Phone:":                                   "Téléphone :",
+	"Price":                                    "Prix",
+	"Reservation Summary":                      "Récapitulatif de la réservation",
+	"Your rooms are booked together, each with its own confirmation code.":  "Vos chambres sont réservées ensemble, chacune avec son propre code de confirmation.",
+	"Keep them with your email address to look up or cancel a reservation.": "Conservez-les avec votre adresse e-mail pour consulter ou annuler une réservation.",
+	"Your confirmation code is": "Votre code de confirmation est",
+	"Keep it with your email address to look up or cancel your reservation.": "Conservez-le avec votre adresse e-mail pour consulter ou annuler votre réservation.",
+	"confirmation code %s":  "code de confirmation %s",
+	"Find Your Reservation": "Retrouver votre réservation",
+	"Enter the confirmation code from your confirmation email and the email address you booked with.": "Saisissez le code de votre e-mail de confirmation et l'adresse e-mail utilisée pour réserver.",
+	"Confirmation Code:":                                             "Code de confirmation :",
+	"Confirmation code:":                                             "Code de confirmation :",
+	"Find Reservation":                                               "Rechercher",
+	"Your Reservation":                                               "Votre réservation",
+	"This reservation was cancelled on %s.":                          "Cette réservation a été annulée le %s.",
+	"Cancel this reservation? This cannot be undone.":                "Annuler cette réservation ? Cette action est définitive.",
+	"You can change or cancel free of charge until %s.":              "Vous pouvez modifier ou annuler sans frais jusqu'au %s.",
+	"Change Dates or Room":                                           "Modifier les dates ou la chambre",
+	"Cancel Reservation":                                             "Annuler la réservation",
+	"Reservations can only be changed or cancelled online until %s.": "Les réservations ne peuvent être modifiées ou annulées en ligne que jusqu'au %s.",
+	"Please contact us if your plans have changed.":                  "Contactez-nous si vos projets ont changé.",
+	"Change Your Reservation":                                        "Modifier votre réservation",
+	"Change Reservation":                                             "Modifier la réservation",
+	"Back":                                                           "Retour",
+
+	// form errors and messages
+	"This field cannot be blank":                                           "Ce champ est obligatoire",
+	"Invalid email address":                                                "Adresse e-mail invalide",
+	"This field must be at least %d characters long":                       "Ce champ doit comporter au moins %d caractères",
+	"Stays cannot be longer than %d nights":                                "Les séjours ne peuvent pas dépasser %d nuits",
+	"Enter a number from %d to %d":                                         "Saisissez un nombre de %d à %d",
+	"Enter a month formatted as YYYY-MM":                                   "Saisissez un mois au format AAAA-MM",
+	"Choose a room":                                                        "Choisissez une chambre",
+	"Please check the dates":                                               "Veuillez vérifier les dates",
+	"Please check the room and month":                                      "Veuillez vérifier la chambre et le mois",
+	"Room not found":                                                       "Chambre introuvable",
+	"Enter a date formatted as YYYY-MM-DD":                                 "Saisissez une date au format AAAA-MM-JJ",
+	"Arrival cannot be in the past":                                        "L'arrivée ne peut pas être dans le passé",
+	"Departure must be at least one day after arrival":                     "Le départ doit avoir lieu au moins un jour après l'arrivée",
+	"We can take parties of up to %d guests":                               "Nous accueillons des groupes de %d personnes au plus",
+	"We couldn't find a reservation with that confirmation code and email": "Aucune réservation ne correspond à ce code de confirmation et à cet e-mail",
+	"Please choose a room":                                                 "Veuillez choisir une chambre",
+	"%s is not available for these dates":                                  "%s n'est pas disponible à ces dates",
+	"%s was just booked for these dates":                                   "%s vient d'être réservée à ces dates",
+	"can't get reservation from session":                                   "impossible de retrouver la réservation",
+	"Can't get reservation from session":                                   "Impossible de retrouver la réservation",
+	"can't find room":                                                      "chambre introuvable",
+	"Sorry, this room is no longer available for your dates":               "Désolé, cette chambre n'est plus disponible à vos dates",
+	"The rooms you chose cannot sleep your whole party":                    "Les chambres choisies ne peuvent pas accueillir tout votre groupe",
+	"%s sleeps at most %d guests":                                          "%s accueille au plus %d personnes",
+	"Stays in this room must be at least %d nights":                        "Les séjours dans cette chambre doivent durer au moins %d nuits",
+	"Stays in this room can be at most %d nights":                          "Les séjours dans cette chambre ne peuvent pas dépasser %d nuits",
+	"Stays in this room over a weekend must be at least %d nights":         "Les séjours dans cette chambre incluant un week-end doivent durer au moins %d nuits",
+	"This room does not take arrivals on that day of the week":             "Cette chambre n'accepte pas d'arrivée ce jour de la semaine",
+	"This room must be booked at least %d hours ahead":                     "Cette chambre doit être réservée au moins %d heures à l'avance",
+	"Sorry, one of these rooms is no longer available for your dates":      "Désolé, l'une de ces chambres n'est plus disponible à vos dates",
+	"No availability for your party on these dates":                        "Aucune disponibilité pour votre groupe à ces dates",
+	"Please choose valid dates":                                            "Veuillez choisir des dates valides",
+	"This link has expired, please look up your reservation instead":       "Ce lien a expiré, veuillez rechercher votre réservation",
+	"This link is not valid, please look up your reservation instead":      "Ce lien n'est pas valide, veuillez rechercher votre réservation",
+	"Please look up your reservation first":                                "Veuillez d'abord rechercher votre réservation",
+	"This reservation can no longer be cancelled online":                   "Cette réservation ne peut plus être annulée en ligne",
+	"This reservation can no longer be changed online":                     "Cette réservation ne peut plus être modifiée en ligne",
+	"This reservation was already cancelled":                               "Cette réservation a déjà été annulée",
+	"Your reservation has been cancelled":                                  "Votre réservation a été annulée",
+	"Your reservation has been changed":                                    "Votre réservation a été modifiée",
+
+	// emails
+	"Reservation Confirmation": "Confirmation de réservation",
+	"Reservation Changed":      "Réservation modifiée",
+	"Reservation Cancelled":    "Réservation annulée",
+	"Dear %s,":                 "Bonjour %s,",
+	"This is to confirm your reservation of %s from %s to %s.":    "Nous vous confirmons votre réservation de %s du %s au %s.",
+	"Your reservation %s is now for %s from %s to %s.":            "Votre réservation %s porte désormais sur %s du %s au %s.",
+	"Your reservation %s of %s from %s to %s has been cancelled.": "Votre réservation %s de %s du %s au %s a été annulée.",
+	"Total price: %s":                         "Prix total : %s",
+	"View, change or cancel your reservation": "Consulter, modifier ou annuler votre réservation",
+
+	// calendar invitations
+	"%s at Fort Smythe":                           "%s à Fort Smythe",
+	"Confirmation code: %s":                       "Code de confirmation : %s",
+	"Check in from %02d:00, check out by %02d:00": "Arrivée à partir de %02d h, départ avant %02d h",
+}
+
+var spanish = map[string]string{
+	// navigation
+	"Home":           "Inicio",
+	"About":          "Acerca de",
+	"Rooms":          "Habitaciones",
+	"Book Now":       "Reservar",
+	"My Reservation": "Mi reserva",
+	"Contact":        "Contacto",
+	"Login":          "Iniciar sesión",
+	"Language":       "Idioma",
+	"Change":         "Cambiar",
+
+	// pages
+	"Welcome to Fort Smythe Bed and Breakfast": "Bienvenido al Bed and Breakfast de Fort Smythe",
+	"Make Reservation Now":                     "Reservar ahora",
+	"Our Rooms":                                "Nuestras habitaciones",
+	"Sleeps %d":                                "Para %d personas",
+	"sleeps %d":                                "para %d personas",
+	"sleeps %d together":                       "para %d personas en total",
+	"from %s per night":                        "desde %s por noche",
+	"View room":                                "Ver habitación",
+	"Check Availability":                       "Consultar disponibilidad",
+	"Search for Availability":                  "Buscar disponibilidad",
+	"Search Availability":                      "Buscar",
+	"Arrival":                                  "Llegada",
+	"Departure":                                "Salida",
+	"Adults":                                   "Adultos",
+	"Children":                                 "Niños",
+	"Choose a Room":                            "Elija una habitación",
+	"Make Reservation":                         "Reservar",
+	"Reservation details":                      "Detalles de la reserva",
+	"Reservation":                              "Reserva",
+	"Room:":                                    "Habitación:",
+	"Arrival:":                                 "Llegada:",
+	"Departure:":                               "Salida:",
+	"Guests:":                                  "Huéspedes:",
+	"%d adult(s)":                              "%d adulto(s)",
+	"%d child(ren)":                            "%d niño(s)",
+	"Total:":                                   "Total:",
+	"%s for %d night(s)":                       "%s por %d noche(s)",
+	"%s to %s":                                 "del %s al %s",
+	"%s weekend":                               "%s fin de semana",
+	"First Name:":                              "Nombre:",
+	"Last Name:":                               "Apellidos:",
+	"Name:":                                    "Nombre:",
+	"Email:":                                   "Correo electrónico:",
+	"Phone:":                                   "Teléfono:",
+	"Price":                                    "Precio",
+	"Reservation Summary":                      "Resumen de la reserva",
+	"Your rooms are booked together, each with its own confirmation code.":  "Sus habitaciones están reservadas juntas, cada una con su propio código de confirmación.",
+	"Keep them with your email address to look up or cancel a reservation.": "Guárdelos con su correo electrónico para consultar o cancelar una reserva.",
+	"Your confirmation code is": "Su código de confirmación es",
+	"Keep it with your email address to look up or cancel your reservation.": "Guárdelo con su correo electrónico para consultar o cancelar su reserva.",
+	"confirmation code %s":  "código de confirmación %s",
+	"Find Your Reservation": "Buscar su reserva",
+	"Enter the confirmation code from your confirmation email and the email address you booked with.": "Introduzca el código de su correo de confirmación y el correo electrónico con el que reservó.",
+	"Confirmation Code:":                                             "Código de confirmación:",
+	"Confirmation code:":                                             "Código de confirmación:",
+	"Find Reservation":                                               "Buscar",
+	"Your Reservation":                                               "Su reserva",
+	"This reservation was cancelled on %s.":                          "Esta reserva se canceló el %s.",
+	"Cancel this reservation? This cannot be undone.":                "¿Cancelar esta reserva? No se puede deshacer.",
+	"You can change or cancel free of charge until %s.":              "Puede cambiar o cancelar sin coste hasta el %s.",
+	"Change Dates or Room":                                           "Cambiar fechas o habitación",
+	"Cancel Reservation":                                             "Cancelar reserva",
+	"Reservations can only be changed or cancelled online until %s.": "Las reservas solo se pueden cambiar o cancelar en línea hasta el %s.",
+	"Please contact us if your plans have changed.":                  "Contáctenos si sus planes han cambiado.",
+	"Change Your Reservation":                                        "Cambiar su reserva",
+	"Change Reservation":                                             "Cambiar reserva",
+	"Back":                                                           "Volver",
+
+	// form errors and messages
+	"This field cannot be blank":                                           "Este campo es obligatorio",
+	"Invalid email address":                                                "Correo electrónico no válido",
+	"This field must be at least %d characters long":                       "Este campo debe tener al menos %d caracteres",
+	"Stays cannot be longer than %d nights":                                "Las estancias no pueden superar las %d noches",
+	"Enter a number from %d to %d":                                         "Introduzca un número del %d al %d",
+	"Enter a month formatted as YYYY-MM":                                   "Introduzca un mes con el formato AAAA-MM",
+	"Choose a room":                                                        "Elija una habitación",
+	"Please check the dates":                                               "Compruebe las fechas",
+	"Please check the room and month":                                      "Compruebe la habitación y el mes",
+	"Room not found":                                                       "Habitación no encontrada",
+	"Enter a date formatted as YYYY-MM-DD":                                 "Introduzca una fecha con el formato AAAA-MM-DD",
+	"Arrival cannot be in the past":                                        "La llegada no puede ser en el pasado",
+	"Departure must be at least one day after arrival":                     "La salida debe ser al menos un día después de la llegada",
+	"We can take parties of up to %d guests":                               "Admitimos grupos de hasta %d personas",
+	"We couldn't find a reservation with that confirmation code and email": "No encontramos ninguna reserva con ese código de confirmación y correo electrónico",
+	"Please choose a room":                                                 "Elija una habitación",
+	"%s is not available for these dates":                                  "%s no está disponible en estas fechas",
+	"%s was just booked for these dates":                                   "%s se acaba de reservar para estas fechas",
+	"can't get reservation from session":                                   "no se encuentra la reserva",
+	"Can't get reservation from session":                                   "No se encuentra la reserva",
+	"can't find room":                                                      "no se encuentra la habitación",
+	"Sorry, this room is no longer available for your dates":               "Lo sentimos, esta habitación ya no está disponible en sus fechas",
+	"The rooms you chose cannot sleep your whole party":                    "Las habitaciones elegidas no pueden alojar a todo su grupo",
+	"%s sleeps at most %d guests":                                          "%s aloja como máximo a %d personas",
+	"Stays in this room must be at least %d nights":                        "Las estancias en esta habitación deben ser de al menos %d noches",
+	"Stays in this room can be at most %d nights":                          "Las estancias en esta habitación no pueden superar las %d noches",
+	"Stays in this room over a weekend must be at least %d nights":         "Las estancias en esta habitación que incluyen un fin de semana deben ser de al menos %d noches",
+	"This room does not take arrivals on that day of the week":             "Esta habitación no admite llegadas ese día de la semana",
+	"This room must be booked at least %d hours ahead":                     "Esta habitación debe reservarse con al menos %d horas de antelación",
+	"Sorry, one of these rooms is no longer available for your dates":      "Lo sentimos, una de estas habitaciones ya no está disponible en sus fechas",
+	"No availability for your party on these dates":                        "No hay disponibilidad para su grupo en estas fechas",
+	"Please choose valid dates":                                            "Elija fechas válidas",
+	"This link has expired, please look up your reservation instead":       "Este enlace ha caducado, busque su reserva",
+	"This link is not valid, please look up your reservation instead":      "Este enlace no es válido, busque su reserva",
+	"Please look up your reservation first":                                "Primero busque su reserva",
+	"This reservation can no longer be cancelled online":                   "Esta reserva ya no se puede cancelar en línea",
+	"This reservation can no longer be changed online":                     "Esta reserva ya no se puede cambiar en línea",
+	"This reservation was already cancelled":                               "Esta reserva ya estaba cancelada",
+	"Your reservation has been cancelled":                                  "Su reserva ha sido cancelada",
+	"Your reservation has been changed":                                    "Su reserva ha sido cambiada",
+
+	// emails
+	"Reservation Confirmation": "Confirmación de reserva",
+	"Reservation Changed":      "Reserva cambiada",
+	"Reservation Cancelled":    "Reserva cancelada",
+	"Dear %s,":                 "Estimado/a %s:",
+	"This is to confirm your reservation of %s from %s to %s.":    "Le confirmamos su reserva de %s del %s al %s.",
+	"Your reservation %s is now for %s from %s to %s.":            "Su reserva %s es ahora de %s del %s al %s.",
+	"Your reservation %s of %s from %s to %s has been cancelled.": "Su reserva %s de %s del %s al %s ha sido cancelada.",
+	"Total price: %s":                         "Precio total: %s",
+	"View, change or cancel your reservation": "Consultar, cambiar o cancelar su reserva",
+
+	// calendar invitations
+	"%s at Fort Smythe":                           "%s en Fort Smythe",
+	"Confirmation code: %s":                       "Código de confirmación: %s",
+	"Check in from %02d:00, check out by %02d:00": "Entrada a partir de las %02d:00, salida antes de las %02d:00",
+}
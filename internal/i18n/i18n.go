@@ -0,0 +1,173 @@
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CookieName is the cookie that keeps the language a guest picked
+const CookieName = "lang"
+
+// Locale is a language the site and its emails are shown in
+type Locale struct {
+	// Tag is the language's primary subtag, e.g. "fr"
+	Tag string
+	// Name is the language's name in the language itself
+	Name string
+	// DateLayout and DateTimeLayout are time layouts with English month names, which FormatDate and
+	// FormatDateTime replace with the locale's own
+	DateLayout     string
+	DateTimeLayout string
+	months         [12]string
+	messages       map[string]string
+}
+
+// Default is the locale used when nothing better matches; its messages are the catalogue's keys
+var Default = &Locale{
+	Tag:            "en",
+	Name:           "English",
+	DateLayout:     "January 2, 2006",
+	DateTimeLayout: "January 2, 2006 15:04",
+}
+
+// Supported lists every locale, Default first
+var Supported = []*Locale{
+	Default,
+	{
+		Tag:            "fr",
+		Name:           "Français",
+		DateLayout:     "2 January 2006",
+		DateTimeLayout: "2 January 2006 15:04",
+		months: [12]string{
+			"janvier", "février", "mars", "avril", "mai", "juin",
+			"juillet", "août", "septembre", "octobre", "novembre", "décembre",
+		},
+		messages: french,
+	},
+	{
+		Tag:            "es",
+		Name:           "Español",
+		DateLayout:     "2 de January de 2006",
+		DateTimeLayout: "2 de January de 2006 15:04",
+		months: [12]string{
+			"enero", "febrero", "marzo", "abril", "mayo", "junio",
+			"julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre",
+		},
+		messages: spanish,
+	},
+}
+
+// Lookup returns the supported locale with the given tag
+func Lookup(tag string) (*Locale, bool) {
+	for _, l := range Supported {
+		if strings.EqualFold(l.Tag, tag) {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// Negotiate picks the locale of a request: the one the guest chose, kept in the lang cookie, if it is
+// supported, otherwise the best supported match for the Accept-Language header, otherwise Default
+func Negotiate(cookie, acceptLanguage string) *Locale {
+	if l, ok := Lookup(cookie); ok {
+		return l
+	}
+
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag == "" || tag == "*" || q <= 0 {
+			continue
+		}
+
+		// en-GB and en-US both get en
+		primary, _, _ := strings.Cut(tag, "-")
+		tags = append(tags, weighted{primary, q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, w := range tags {
+		if l, ok := Lookup(w.tag); ok {
+			return l
+		}
+	}
+
+	return Default
+}
+
+// T translates the English message key, formatting it with args when there are any. Messages missing
+// from the locale's catalogue are shown in English.
+func (l *Locale) T(key string, args ...any) string {
+	msg, ok := l.messages[key]
+	if !ok {
+		msg = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// FormatDate formats the date of t the way the locale writes dates
+func (l *Locale) FormatDate(t time.Time) string {
+	return l.format(t, l.DateLayout)
+}
+
+// FormatDateTime formats t with its time of day the way the locale writes them
+func (l *Locale) FormatDateTime(t time.Time) string {
+	return l.format(t, l.DateTimeLayout)
+}
+
+// format formats t with layout, swapping the English month name for the locale's
+func (l *Locale) format(t time.Time, layout string) string {
+	s := t.Format(layout)
+	if l.months[0] != "" {
+		s = strings.Replace(s, t.Month().String(), l.months[t.Month()-1], 1)
+	}
+	return s
+}
+
+// Funcs returns the template functions that translate messages and format dates for the locale, and lang,
+// which returns its tag
+func (l *Locale) Funcs() map[string]any {
+	return map[string]any{
+		"lang":     func() string { return l.Tag },
+		"t":        l.T,
+		"date":     l.FormatDate,
+		"dateTime": l.FormatDateTime,
+	}
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the locale l
+func NewContext(ctx context.Context, l *Locale) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the locale carried by ctx, or Default when it carries none
+func FromContext(ctx context.Context) *Locale {
+	if l, ok := ctx.Value(contextKey{}).(*Locale); ok {
+		return l
+	}
+	return Default
+}
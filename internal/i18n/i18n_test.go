@@ -0,0 +1,131 @@
+package i18n
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name           string
+		cookie         string
+		acceptLanguage string
+		expected       string
+	}{
+		{"nothing", "", "", "en"},
+		{"exact", "", "fr", "fr"},
+		{"region", "", "es-MX,es;q=0.9", "es"},
+		{"unsupported first", "", "de-DE,de;q=0.9,fr;q=0.8", "fr"},
+		{"weights", "", "fr;q=0.5,es;q=0.8", "es"},
+		{"refused", "", "fr;q=0,*;q=0.1", "en"},
+		{"bad weight", "", "fr;q=abc,es", "es"},
+		{"unsupported", "", "de,it", "en"},
+		{"cookie wins", "es", "fr", "es"},
+		{"unsupported cookie", "de", "fr", "fr"},
+	}
+
+	for _, e := range tests {
+		if got := Negotiate(e.cookie, e.acceptLanguage).Tag; got != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestLocale_T(t *testing.T) {
+	fr, _ := Lookup("fr")
+
+	if got := fr.T("Home"); got != "Accueil" {
+		t.Errorf("expected Accueil, got %q", got)
+	}
+	if got := fr.T("%d adult(s)", 2); got != "2 adulte(s)" {
+		t.Errorf("expected 2 adulte(s), got %q", got)
+	}
+	if got := fr.T("Not in the catalogue"); got != "Not in the catalogue" {
+		t.Errorf("expected a missing message in English, got %q", got)
+	}
+	if got := Default.T("Sleeps %d", 4); got != "Sleeps 4" {
+		t.Errorf("expected Sleeps 4, got %q", got)
+	}
+}
+
+func TestLocale_FormatDate(t *testing.T) {
+	d := time.Date(2050, time.August, 3, 14, 30, 0, 0, time.UTC)
+
+	tests := map[string][2]string{
+		"en": {"August 3, 2050", "August 3, 2050 14:30"},
+		"fr": {"3 août 2050", "3 août 2050 14:30"},
+		"es": {"3 de agosto de 2050", "3 de agosto de 2050 14:30"},
+	}
+
+	for tag, expected := range tests {
+		l, ok := Lookup(tag)
+		if !ok {
+			t.Fatalf("%s is not supported", tag)
+		}
+		if got := l.FormatDate(d); got != expected[0] {
+			t.Errorf("%s: expected date %q, got %q", tag, expected[0], got)
+		}
+		if got := l.FormatDateTime(d); got != expected[1] {
+			t.Errorf("%s: expected date and time %q, got %q", tag, expected[1], got)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	if FromContext(context.Background()) != Default {
+		t.Error("expected a context without a locale to give the default")
+	}
+
+	es, _ := Lookup("es")
+	if FromContext(NewContext(context.Background(), es)) != es {
+		t.Error("expected the locale put in the context")
+	}
+}
+
+// TestCatalogues checks every message the pages, emails, handlers, forms and booking rules translate is in every
+// catalogue
+func TestCatalogues(t *testing.T) {
+	sources := map[string]*regexp.Regexp{
+		"../../templates/*.tmpl":       regexp.MustCompile(`[{(]t "([^"]+)"`),
+		"../../email-templates/*.tmpl": regexp.MustCompile(`[{(]t "([^"]+)"`),
+		"../handlers/*.go":             regexp.MustCompile(`\b(?:t\(r, |l\.T\()"([^"]+)"`),
+		"../bookingrules/*.go":         regexp.MustCompile(`\bviolation\("([^"]+)"`),
+		"../forms/*.go":                regexp.MustCompile(`\bf\.message\("([^"]+)"`),
+	}
+
+	keys := map[string]bool{}
+	for pattern, re := range sources {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, m := range re.FindAllStringSubmatch(string(data), -1) {
+				keys[m[1]] = true
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		t.Fatal("expected to find messages to translate")
+	}
+
+	for _, l := range Supported {
+		if l == Default {
+			continue
+		}
+		for key := range keys {
+			if _, ok := l.messages[key]; !ok {
+				t.Errorf("%s: no translation for %q", l.Tag, key)
+			}
+		}
+	}
+}
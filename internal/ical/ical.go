@@ -0,0 +1,122 @@
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Methods of an iCalendar invitation: REQUEST adds or updates an event, CANCEL removes it
+const (
+	MethodRequest = "REQUEST"
+	MethodCancel  = "CANCEL"
+)
+
+// maxLineOctets is the longest a content line may be before it has to be folded
+const maxLineOctets = 75
+
+// Event is an all-day calendar entry. Only the dates of Start and End are used, End being the day after
+// the event, so it covers the same days wherever the calendar is.
+type Event struct {
+	// UID identifies the event across updates, so a CANCEL removes the entry a REQUEST added
+	UID string
+	// Sequence must grow with every update of the event
+	Sequence    int
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Location    string
+	Description string
+	URL         string
+	// Organizer and Attendee are email addresses
+	Organizer string
+	Attendee  string
+}
+
+// ContentType returns the MIME type of a calendar sent with method
+func ContentType(method string) string {
+	return fmt.Sprintf("text/calendar; charset=utf-8; method=%s", method)
+}
+
+// Calendar returns the iCalendar object sending e with method, stamped with now
+func Calendar(method string, e Event, now time.Time) []byte {
+	buf := new(bytes.Buffer)
+
+	status := "CONFIRMED"
+	if method == MethodCancel {
+		status = "CANCELLED"
+	}
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Fort Smythe//Reservations//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:" + method,
+		"BEGIN:VEVENT",
+		"UID:" + escape(e.UID),
+		fmt.Sprintf("SEQUENCE:%d", e.Sequence),
+		"DTSTAMP:" + now.UTC().Format("20060102T150405Z"),
+		"DTSTART;VALUE=DATE:" + e.Start.Format("20060102"),
+		"DTEND;VALUE=DATE:" + e.End.Format("20060102"),
+		"SUMMARY:" + escape(e.Summary),
+	}
+	if e.Location != "" {
+		lines = append(lines, "LOCATION:"+escape(e.Location))
+	}
+	if e.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+escape(e.Description))
+	}
+	if e.URL != "" {
+		lines = append(lines, "URL:"+e.URL)
+	}
+	if e.Organizer != "" {
+		lines = append(lines, "ORGANIZER:mailto:"+e.Organizer)
+	}
+	if e.Attendee != "" {
+		lines = append(lines, "ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=ACCEPTED;RSVP=FALSE:mailto:"+e.Attendee)
+	}
+	lines = append(lines, "STATUS:"+status, "TRANSP:OPAQUE", "END:VEVENT", "END:VCALENDAR")
+
+	for _, line := range lines {
+		buf.WriteString(fold(line))
+		buf.WriteString("\r\n")
+	}
+
+	return buf.Bytes()
+}
+
+// escape escapes the characters that have a meaning in iCalendar text values
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// fold splits a content line longer than maxLineOctets into lines continued by a leading space, without
+// splitting a UTF-8 character
+func fold(line string) string {
+	var b strings.Builder
+
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// the leading space of a continued line counts towards its length
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(line)
+
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var event = Event{
+	UID:         "reservation-ABCD2345@localhost",
+	Start:       time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+	End:         time.Date(2050, 1, 3, 0, 0, 0, 0, time.UTC),
+	Summary:     "Fort Smythe: General's Quarters",
+	Location:    "1 Fort Road, Smythe",
+	Description: "Confirmation code ABCD2345\nCheck in from 15:00",
+	URL:         "http://localhost:8080/manage/token",
+	Organizer:   "me@here.com",
+	Attendee:    "john@smith.com",
+}
+
+var now = time.Date(2049, 12, 1, 9, 30, 0, 0, time.UTC)
+
+func TestCalendar(t *testing.T) {
+	cal := string(Calendar(MethodRequest, event, now))
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"METHOD:REQUEST\r\n",
+		"UID:reservation-ABCD2345@localhost\r\n",
+		"SEQUENCE:0\r\n",
+		"DTSTAMP:20491201T093000Z\r\n",
+		"DTSTART;VALUE=DATE:20500101\r\n",
+		"DTEND;VALUE=DATE:20500103\r\n",
+		`LOCATION:1 Fort Road\, Smythe` + "\r\n",
+		`DESCRIPTION:Confirmation code ABCD2345\nCheck in from 15:00` + "\r\n",
+		"ORGANIZER:mailto:me@here.com\r\n",
+		"STATUS:CONFIRMED\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(cal, want) {
+			t.Errorf("expected calendar to contain %q, got:\n%s", want, cal)
+		}
+	}
+
+	if strings.Contains(strings.ReplaceAll(cal, "\r\n", ""), "\n") {
+		t.Error("expected every line to end in CRLF")
+	}
+}
+
+func TestCalendar_Cancel(t *testing.T) {
+	cancelled := event
+	cancelled.Sequence = 1
+	cal := string(Calendar(MethodCancel, cancelled, now))
+
+	for _, want := range []string{"METHOD:CANCEL\r\n", "SEQUENCE:1\r\n", "STATUS:CANCELLED\r\n", "UID:" + event.UID} {
+		if !strings.Contains(cal, want) {
+			t.Errorf("expected cancellation to contain %q, got:\n%s", want, cal)
+		}
+	}
+}
+
+func TestFold(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("é", 100)
+	folded := fold(line)
+
+	for _, l := range strings.Split(folded, "\r\n") {
+		if len(l) > maxLineOctets {
+			t.Errorf("expected lines of at most %d octets, got %d", maxLineOctets, len(l))
+		}
+		if !strings.HasPrefix(l, " ") && l != folded[:len(l)] {
+			t.Errorf("expected continued line %q to start with a space", l)
+		}
+	}
+
+	if strings.ReplaceAll(folded, "\r\n ", "") != line {
+		t.Error("expected unfolding to give back the line")
+	}
+
+	if fold("SUMMARY:short") != "SUMMARY:short" {
+		t.Error("expected a short line to be left alone")
+	}
+}
+
+func TestContentType(t *testing.T) {
+	if got := ContentType(MethodCancel); got != "text/calendar; charset=utf-8; method=CANCEL" {
+		t.Errorf("unexpected content type %q", got)
+	}
+}
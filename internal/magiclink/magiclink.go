@@ -0,0 +1,54 @@
+package magiclink
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed or whose signature does not match
+var ErrInvalidToken = errors.New("magic link token is invalid")
+
+// ErrExpiredToken is returned for correctly signed tokens whose expiry has passed
+var ErrExpiredToken = errors.New("magic link token has expired")
+
+// Sign returns a token that carries a reservation confirmation code until expires,
+// in the form <code>.<expiry unix seconds>.<signature>
+func Sign(key []byte, code string, expires time.Time) string {
+	payload := code + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + signature(key, payload)
+}
+
+// Verify checks the signature and expiry of a token made by Sign and returns the confirmation code it carries
+func Verify(key []byte, token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", ErrInvalidToken
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(signature(key, payload))) {
+		return "", ErrInvalidToken
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	if !now.Before(time.Unix(expires, 0)) {
+		return "", ErrExpiredToken
+	}
+
+	return parts[0], nil
+}
+
+// signature returns the URL-safe HMAC-SHA256 of payload under key
+func signature(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
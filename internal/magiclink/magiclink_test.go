@@ -0,0 +1,51 @@
+package magiclink
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var key = []byte("test signing key")
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)
+	token := Sign(key, "ABCD2345", now.Add(time.Hour))
+
+	code, err := Verify(key, token, now)
+	if err != nil {
+		t.Fatalf("expected a freshly signed token to verify, got %v", err)
+	}
+	if code != "ABCD2345" {
+		t.Errorf("expected code ABCD2345, got %s", code)
+	}
+}
+
+func TestVerify_Rejects(t *testing.T) {
+	now := time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)
+	token := Sign(key, "ABCD2345", now.Add(time.Hour))
+	parts := strings.Split(token, ".")
+
+	tests := []struct {
+		name     string
+		key      []byte
+		token    string
+		now      time.Time
+		expected error
+	}{
+		{"expired", key, token, now.Add(2 * time.Hour), ErrExpiredToken},
+		{"other code", key, "ZZZZ2345." + parts[1] + "." + parts[2], now, ErrInvalidToken},
+		{"extended expiry", key, parts[0] + ".9999999999." + parts[2], now, ErrInvalidToken},
+		{"other key", []byte("another key"), token, now, ErrInvalidToken},
+		{"truncated", key, parts[0] + "." + parts[1], now, ErrInvalidToken},
+		{"empty", key, "", now, ErrInvalidToken},
+	}
+
+	for _, e := range tests {
+		_, err := Verify(e.key, e.token, e.now)
+		if !errors.Is(err, e.expected) {
+			t.Errorf("%s: expected %v, got %v", e.name, e.expected, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package mailer
+
+import (
+	"fmt"
+	mail "github.com/xhit/go-simple-mail/v2"
+	"learn-golang/internal/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer sends email
+type Mailer interface {
+	Send(models.MailData) error
+}
+
+// TLS modes of an SMTP connection
+const (
+	TLSNone     = "none"
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+)
+
+// SMTP sends email through an SMTP server
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// TLS is one of TLSNone, TLSStartTLS or TLSImplicit
+	TLS     string
+	Timeout time.Duration
+}
+
+// Send sends m through the SMTP server, connecting afresh for each message
+func (s *SMTP) Send(m models.MailData) error {
+	email, err := newMessage(m)
+	if err != nil {
+		return err
+	}
+
+	server := mail.NewSMTPClient()
+	server.Host = s.Host
+	server.Port = s.Port
+	server.Username = s.Username
+	server.Password = s.Password
+	server.KeepAlive = false
+	server.ConnectTimeout = s.Timeout
+	server.SendTimeout = s.Timeout
+
+	if s.Username == "" {
+		server.Authentication = mail.AuthNone
+	}
+
+	switch s.TLS {
+	case TLSNone, "":
+		server.Encryption = mail.EncryptionNone
+	case TLSStartTLS:
+		server.Encryption = mail.EncryptionSTARTTLS
+	case TLSImplicit:
+		server.Encryption = mail.EncryptionSSLTLS
+	default:
+		return fmt.Errorf("unknown SMTP TLS mode %q", s.TLS)
+	}
+
+	client, err := server.Connect()
+	if err != nil {
+		return err
+	}
+
+	return email.Send(client)
+}
+
+// FileDrop writes each email to a .eml file in Dir instead of sending it, for development
+type FileDrop struct {
+	Dir string
+}
+
+// Send writes m to a new .eml file named after the time it was sent and its subject
+func (f *FileDrop) Send(m models.MailData) error {
+	email, err := newMessage(m)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(f.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000000"), fileSafe(m.Subject))
+	return os.WriteFile(filepath.Join(f.Dir, name), []byte(email.GetMessage()), 0o644)
+}
+
+// fileSafe turns s into something usable in a file name, e.g. "Reservation Confirmation" into
+// "reservation-confirmation"
+func fileSafe(s string) string {
+	return strings.Trim(
+		strings.Map(
+			func(r rune) rune {
+				if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+					return r
+				}
+				return '-'
+			}, strings.ToLower(s),
+		), "-",
+	)
+}
+
+// Recorder keeps the email it is asked to send in memory so tests can check it
+type Recorder struct {
+	mu   sync.Mutex
+	sent []models.MailData
+}
+
+// Send records m
+func (r *Recorder) Send(m models.MailData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sent = append(r.sent, m)
+	return nil
+}
+
+// Sent returns the email recorded so far, oldest first
+func (r *Recorder) Sent() []models.MailData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]models.MailData(nil), r.sent...)
+}
+
+// Reset forgets the email recorded so far
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sent = nil
+}
+
+// newMessage builds the email for m: its plain text part with the html part as an alternative, or just the
+// html part when it has no text, followed by its attachments
+func newMessage(m models.MailData) (*mail.Email, error) {
+	email := mail.NewMSG()
+	email.SetFrom(m.From).AddTo(m.To).SetSubject(m.Subject)
+	if m.Text == "" {
+		email.SetBody(mail.TextHTML, m.Content)
+	} else {
+		email.SetBody(mail.TextPlain, m.Text)
+		email.AddAlternative(mail.TextHTML, m.Content)
+	}
+	for _, a := range m.Attachments {
+		email.Attach(&mail.File{Name: a.Name, MimeType: a.ContentType, Data: a.Data})
+	}
+
+	return email, email.GetError()
+}
@@ -0,0 +1,134 @@
+package mailer
+
+import (
+	"learn-golang/internal/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var msg = models.MailData{
+	To:      "john@smith.com",
+	From:    "me@here.com",
+	Subject: "Reservation Confirmation",
+	Content: "<strong>Reservation Confirmation</strong>",
+}
+
+func TestFileDrop_Send(t *testing.T) {
+	dir := t.TempDir()
+	f := &FileDrop{Dir: filepath.Join(dir, "mail")}
+
+	err := f.Send(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withText := msg
+	withText.Text = "Reservation Confirmation"
+	err = f.Send(withText)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "mail", "*-reservation-confirmation.eml"))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 .eml files, got %d", len(files))
+	}
+
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		eml := string(data)
+		for _, want := range []string{"To: <john@smith.com>", "Subject: Reservation Confirmation", "Reservation Confirmation</strong>"} {
+			if !strings.Contains(eml, want) {
+				t.Errorf("expected %s to contain %q", filepath.Base(file), want)
+			}
+		}
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	email, err := newMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eml := email.GetMessage(); !strings.Contains(eml, "Content-Type: text/html") ||
+		strings.Contains(eml, "multipart/alternative") {
+		t.Errorf("expected a message without text to be html only, got:\n%s", eml)
+	}
+
+	withText := msg
+	withText.Text = "Dear John"
+	email, err = newMessage(withText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eml := email.GetMessage()
+	for _, want := range []string{"multipart/alternative", "Content-Type: text/plain", "Dear John", "Content-Type: text/html"} {
+		if !strings.Contains(eml, want) {
+			t.Errorf("expected a message with text to contain %q, got:\n%s", want, eml)
+		}
+	}
+
+	withInvite := withText
+	withInvite.Attachments = []models.Attachment{
+		{Name: "reservation.ics", ContentType: "text/calendar; method=REQUEST", Data: []byte("BEGIN:VCALENDAR")},
+	}
+	email, err = newMessage(withInvite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eml = email.GetMessage()
+	for _, want := range []string{"multipart/mixed", "Content-Type: text/calendar; method=REQUEST", `filename="reservation.ics"`} {
+		if !strings.Contains(eml, want) {
+			t.Errorf("expected a message with an attachment to contain %q, got:\n%s", want, eml)
+		}
+	}
+
+	withBadAddress := msg
+	withBadAddress.To = "not an address"
+	_, err = newMessage(withBadAddress)
+	if err == nil {
+		t.Error("expected an error for a bad address")
+	}
+}
+
+func TestSMTP_Send_UnknownTLS(t *testing.T) {
+	s := &SMTP{Host: "localhost", Port: 1025, TLS: "ssl3"}
+	if s.Send(msg) == nil {
+		t.Error("expected an error for an unknown TLS mode")
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	var r Recorder
+	_ = r.Send(msg)
+	_ = r.Send(msg)
+
+	if len(r.Sent()) != 2 {
+		t.Errorf("expected 2 recorded messages, got %d", len(r.Sent()))
+	}
+	if !reflect.DeepEqual(r.Sent()[0], msg) {
+		t.Errorf("expected the recorded message to be %+v, got %+v", msg, r.Sent()[0])
+	}
+
+	r.Reset()
+	if len(r.Sent()) != 0 {
+		t.Errorf("expected no recorded messages after Reset, got %d", len(r.Sent()))
+	}
+}
+
+func TestFileSafe(t *testing.T) {
+	tests := map[string]string{
+		"Reservation Confirmation": "reservation-confirmation",
+		"Héllo, World!":            "h-llo--world",
+		"":                         "",
+	}
+
+	for in, expected := range tests {
+		if got := fileSafe(in); got != expected {
+			t.Errorf("fileSafe(%q): expected %q, got %q", in, expected, got)
+		}
+	}
+}
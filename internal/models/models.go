@@ -18,12 +18,85 @@ type User struct {
 
 // Room is the room model
 type Room struct {
+	ID               int
+	RoomName         string
+	Slug             string
+	Description      string
+	Capacity         int
+	NightlyRate      int
+	WeekendSurcharge int
+	Retired          bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Photos           []RoomPhoto
+}
+
+// RoomPhoto is the room photo model
+type RoomPhoto struct {
 	ID        int
-	RoomName  string
+	RoomID    int
+	Path      string
+	Caption   string
+	SortOrder int
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+// SeasonalRate is the seasonal rate model, overriding a room's nightly rate from StartDate through EndDate
+type SeasonalRate struct {
+	ID          int
+	RoomID      int
+	Name        string
+	StartDate   time.Time
+	EndDate     time.Time
+	NightlyRate int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// BookingRules are the stays a room accepts; a zero value means no limit
+type BookingRules struct {
+	ID               int
+	RoomID           int
+	MinNights        int
+	MaxNights        int
+	WeekendMinNights int
+	NoArrivalDays    int // bit n is set when guests may not arrive on time.Weekday(n)
+	LeadTimeHours    int
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// NoArrivalOn returns true if guests may not arrive on day
+func (b BookingRules) NoArrivalOn(day time.Weekday) bool {
+	return b.NoArrivalDays&(1<<day) != 0
+}
+
+// NightPrice is the price of one night of a stay
+type NightPrice struct {
+	Date      time.Time
+	Rate      int
+	Surcharge int
+	Season    string
+}
+
+// Total returns the price of the night including any surcharge
+func (n NightPrice) Total() int {
+	return n.Rate + n.Surcharge
+}
+
+// DayAvailability is whether a room is free for the night of Date
+type DayAvailability struct {
+	Date      time.Time
+	Available bool
+}
+
+// Restriction IDs seeded by the restrictions migration
+const (
+	RestrictionReservation = 1
+	RestrictionOwnerBlock  = 2
+)
+
 // Restriction is the restrictions model
 type Restriction struct {
 	ID              int
@@ -34,17 +107,40 @@ type Restriction struct {
 
 // Reservation is the reservation model
 type Reservation struct {
-	ID        int
-	FirstName string
-	LastName  string
-	Email     string
-	Phone     string
-	StartDate time.Time
-	EndDate   time.Time
-	RoomID    int
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Room      Room
+	ID               int
+	FirstName        string
+	LastName         string
+	Email            string
+	Phone            string
+	StartDate        time.Time
+	EndDate          time.Time
+	RoomID           int
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Room             Room
+	Processed        int
+	TotalPrice       int
+	Nights           []NightPrice
+	ConfirmationCode string
+	CancelledAt      time.Time
+	Adults           int
+	Children         int
+}
+
+// Cancelled reports whether the reservation has been cancelled
+func (r Reservation) Cancelled() bool {
+	return !r.CancelledAt.IsZero()
+}
+
+// ReservationFilter holds the paging, sorting and filtering options used when listing reservations
+type ReservationFilter struct {
+	Page     int
+	PerPage  int
+	SortBy   string
+	SortDesc bool
+	From     time.Time
+	To       time.Time
+	RoomID   int
 }
 
 // RoomRestriction is the room restriction model
@@ -62,11 +158,83 @@ type RoomRestriction struct {
 	Restriction   Restriction
 }
 
-// MailData holds an email data
+// API key scopes; ScopeWriteReservations grants ScopeReadReservations too, and ScopeAdmin every other scope
+const (
+	ScopeReadAvailability  = "read-availability"
+	ScopeReadReservations  = "read-reservations"
+	ScopeWriteReservations = "write-reservations"
+	ScopeAdmin             = "admin"
+)
+
+// APIScopes lists every scope an API key can be given
+var APIScopes = []string{ScopeReadAvailability, ScopeReadReservations, ScopeWriteReservations, ScopeAdmin}
+
+// APIKey is the API key model; only the hash of the key itself is stored
+type APIKey struct {
+	ID         int
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []string
+	LastUsedAt time.Time
+	RevokedAt  time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// HasScope reports whether the key grants scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+		// keys that book reservations can always look them up again
+		if s == ScopeWriteReservations && scope == ScopeReadReservations {
+			return true
+		}
+	}
+	return false
+}
+
+// Revoked reports whether the key has been revoked
+func (k APIKey) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+// MailData holds an email data; Content is its html part and Text its plain text alternative
 type MailData struct {
-	To       string
-	From     string
-	Subject  string
-	Content  string
-	Template string
+	To          string
+	From        string
+	Subject     string
+	Content     string
+	Text        string
+	Attachments []Attachment
+}
+
+// Attachment is a file sent with an email
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Outbox message statuses
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead"
+)
+
+// OutboxMessage is an email queued in the outbox; a dead message has used up its attempts and waits for
+// an admin to resend it
+type OutboxMessage struct {
+	ID            int
+	Mail          MailData
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	SentAt        time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
 }
@@ -1,6 +1,9 @@
 package models
 
-import "learn-golang/internal/forms"
+import (
+	"learn-golang/internal/forms"
+	"learn-golang/internal/i18n"
+)
 
 // TemplateData holds data sent from handlers to template
 type TemplateData struct {
@@ -14,4 +17,19 @@ type TemplateData struct {
 	Error           string
 	Form            *forms.Form
 	IsAuthenticated int
+	// Locale is the tag of the language the page is shown in and Locales are the ones a guest can pick
+	Locale  string
+	Locales []*i18n.Locale
+	// CurrentPath is the path and query of the page, so the language picker can come back to it
+	CurrentPath string
+}
+
+// EmailData holds data sent from handlers to email templates
+type EmailData struct {
+	Reservation Reservation
+	// Previous is the reservation as it was before a change
+	Previous   Reservation
+	Room       Room
+	TotalPrice int
+	ManageLink string
 }
@@ -0,0 +1,107 @@
+package outbox
+
+import (
+	"context"
+	"learn-golang/internal/models"
+	"log"
+	"sync"
+	"time"
+)
+
+// Store is the part of the repository the outbox is kept in
+type Store interface {
+	ClaimDueMail(limit int, lease time.Duration) ([]models.OutboxMessage, error)
+	UpdateOutboxMessage(models.OutboxMessage) error
+}
+
+// Pool sends the mail queued in the outbox with a fixed number of workers. A failed message is retried
+// with exponential backoff until it has used up MaxAttempts, after which it is left dead for an admin
+// to resend.
+type Pool struct {
+	Store        Store
+	Send         func(models.MailData) error
+	Workers      int
+	MaxAttempts  int
+	PollInterval time.Duration
+	// Lease is how long a claimed message is hidden from other workers while it is being sent
+	Lease    time.Duration
+	ErrorLog *log.Logger
+}
+
+// maxBackoff caps the wait between two attempts at sending a message
+const maxBackoff = 6 * time.Hour
+
+// Backoff returns how long to wait before the next attempt at a message that has failed attempts times:
+// a minute after the first failure, doubling after each one up to maxBackoff
+func Backoff(attempts int) time.Duration {
+	d := time.Minute
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// Run starts the workers and blocks until ctx is done and every worker has finished the message it was sending
+func (p *Pool) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// work claims and sends one due message at a time, waiting PollInterval whenever the outbox has none
+func (p *Pool) work(ctx context.Context) {
+	for {
+		messages, err := p.Store.ClaimDueMail(1, p.Lease)
+		if err != nil {
+			p.ErrorLog.Println(err)
+		}
+
+		for _, m := range messages {
+			p.deliver(m, time.Now())
+		}
+
+		if len(messages) == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(p.PollInterval):
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// deliver makes one attempt at sending m and records the outcome
+func (p *Pool) deliver(m models.OutboxMessage, now time.Time) {
+	m.Attempts++
+
+	err := p.Send(m.Mail)
+	switch {
+	case err == nil:
+		m.Status = models.OutboxSent
+		m.SentAt = now
+		m.LastError = ""
+	case m.Attempts >= p.MaxAttempts:
+		m.Status = models.OutboxDead
+		m.LastError = err.Error()
+		p.ErrorLog.Printf("giving up on mail %d to %s after %d attempts: %v", m.ID, m.Mail.To, m.Attempts, err)
+	default:
+		m.NextAttemptAt = now.Add(Backoff(m.Attempts))
+		m.LastError = err.Error()
+	}
+
+	err = p.Store.UpdateOutboxMessage(m)
+	if err != nil {
+		p.ErrorLog.Println(err)
+	}
+}
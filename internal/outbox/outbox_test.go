@@ -0,0 +1,160 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"learn-golang/internal/models"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore is an in-memory Store
+type memStore struct {
+	mu       sync.Mutex
+	messages map[int]models.OutboxMessage
+}
+
+func (s *memStore) ClaimDueMail(limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var claimed []models.OutboxMessage
+	now := time.Now()
+	for id, m := range s.messages {
+		if len(claimed) == limit {
+			break
+		}
+		if m.Status == models.OutboxPending && !m.NextAttemptAt.After(now) {
+			m.NextAttemptAt = now.Add(lease)
+			s.messages[id] = m
+			claimed = append(claimed, m)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *memStore) UpdateOutboxMessage(m models.OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages[m.ID] = m
+	return nil
+}
+
+func newPool(store Store, send func(models.MailData) error) *Pool {
+	return &Pool{
+		Store:        store,
+		Send:         send,
+		Workers:      3,
+		MaxAttempts:  3,
+		PollInterval: time.Millisecond,
+		Lease:        time.Minute,
+		ErrorLog:     log.New(io.Discard, "", 0),
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{5, 16 * time.Minute},
+		{9, 256 * time.Minute},
+		{10, maxBackoff},
+		{100, maxBackoff},
+	}
+
+	for _, e := range tests {
+		if got := Backoff(e.attempts); got != e.expected {
+			t.Errorf("Backoff(%d): expected %s, got %s", e.attempts, e.expected, got)
+		}
+	}
+}
+
+func TestPool_Deliver(t *testing.T) {
+	now := time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)
+	failure := errors.New("connection refused")
+
+	tests := []struct {
+		name           string
+		attempts       int
+		sendErr        error
+		expectedStatus string
+		expectedNext   time.Time
+	}{
+		{"sent", 0, nil, models.OutboxSent, time.Time{}},
+		{"first failure", 0, failure, models.OutboxPending, now.Add(time.Minute)},
+		{"second failure", 1, failure, models.OutboxPending, now.Add(2 * time.Minute)},
+		{"last attempt", 2, failure, models.OutboxDead, time.Time{}},
+	}
+
+	for _, e := range tests {
+		store := &memStore{messages: map[int]models.OutboxMessage{}}
+		p := newPool(store, func(models.MailData) error { return e.sendErr })
+
+		p.deliver(models.OutboxMessage{ID: 1, Status: models.OutboxPending, Attempts: e.attempts}, now)
+
+		m := store.messages[1]
+		if m.Status != e.expectedStatus {
+			t.Errorf("%s: expected status %s, got %s", e.name, e.expectedStatus, m.Status)
+		}
+		if m.Attempts != e.attempts+1 {
+			t.Errorf("%s: expected %d attempts, got %d", e.name, e.attempts+1, m.Attempts)
+		}
+		if !m.NextAttemptAt.Equal(e.expectedNext) {
+			t.Errorf("%s: expected next attempt at %s, got %s", e.name, e.expectedNext, m.NextAttemptAt)
+		}
+		if (e.sendErr == nil) != (m.LastError == "") {
+			t.Errorf("%s: unexpected last error %q", e.name, m.LastError)
+		}
+		if (m.Status == models.OutboxSent) == m.SentAt.IsZero() {
+			t.Errorf("%s: unexpected sent at %s", e.name, m.SentAt)
+		}
+	}
+}
+
+func TestPool_Run(t *testing.T) {
+	store := &memStore{messages: map[int]models.OutboxMessage{}}
+	for id := 1; id <= 10; id++ {
+		store.messages[id] = models.OutboxMessage{ID: id, Status: models.OutboxPending, Mail: models.MailData{To: "a@b.com"}}
+	}
+
+	var mu sync.Mutex
+	sent := 0
+	p := newPool(store, func(models.MailData) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent++
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	go func() {
+		for ctx.Err() == nil {
+			mu.Lock()
+			done := sent == 10
+			mu.Unlock()
+			if done {
+				cancel()
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	p.Run(ctx)
+
+	if sent != 10 {
+		t.Errorf("expected every message to be sent once, got %d sends", sent)
+	}
+	for id, m := range store.messages {
+		if m.Status != models.OutboxSent {
+			t.Errorf("expected message %d to be sent, got status %s", id, m.Status)
+		}
+	}
+}
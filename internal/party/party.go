@@ -0,0 +1,115 @@
+package party
+
+import (
+	"learn-golang/internal/models"
+	"sort"
+)
+
+// MaxGuests is the largest party the search form accepts
+const MaxGuests = 12
+
+// MaxRooms is the largest number of rooms offered together for one party
+const MaxRooms = 3
+
+// MaxOptions is the most room options offered for one search
+const MaxOptions = 10
+
+// Option is a set of rooms that together fit a party
+type Option struct {
+	Rooms    []models.Room
+	Capacity int
+}
+
+// Single returns true if the option is one room
+func (o Option) Single() bool {
+	return len(o.Rooms) == 1
+}
+
+// Options returns the single rooms and combinations of up to MaxRooms rooms that fit a party of adults and
+// children, fewest rooms first and then tightest fit first. Every room of a combination needs an adult,
+// and no combination holds a room the party could do without.
+func Options(rooms []models.Room, adults, children int) []Option {
+	guests := adults + children
+	if guests < 1 {
+		return nil
+	}
+
+	sorted := make([]models.Room, len(rooms))
+	copy(sorted, rooms)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Capacity < sorted[j].Capacity
+	})
+
+	var options []Option
+	var pick func(start int, chosen []models.Room, capacity int)
+	pick = func(start int, chosen []models.Room, capacity int) {
+		if capacity >= guests {
+			// sorted by capacity, so the first room chosen is the smallest; without it the party must not fit
+			if capacity-chosen[0].Capacity < guests {
+				options = append(options, Option{Rooms: append([]models.Room(nil), chosen...), Capacity: capacity})
+			}
+			return
+		}
+		if len(chosen) == MaxRooms || len(chosen) == adults {
+			return
+		}
+		for i := start; i < len(sorted); i++ {
+			pick(i+1, append(chosen, sorted[i]), capacity+sorted[i].Capacity)
+		}
+	}
+	pick(0, nil, 0)
+
+	sort.SliceStable(options, func(i, j int) bool {
+		if len(options[i].Rooms) != len(options[j].Rooms) {
+			return len(options[i].Rooms) < len(options[j].Rooms)
+		}
+		return options[i].Capacity < options[j].Capacity
+	})
+
+	if len(options) > MaxOptions {
+		options = options[:MaxOptions]
+	}
+	return options
+}
+
+// Fits reports whether rooms hold a party of adults and children together, with an adult for every room
+func Fits(rooms []models.Room, adults, children int) bool {
+	capacity := 0
+	for _, room := range rooms {
+		capacity += room.Capacity
+	}
+	return len(rooms) <= adults && capacity >= adults+children
+}
+
+// Split shares a party out over rooms: each room gets an adult first, then the remaining adults and the
+// children fill the rooms in order up to their capacity. It returns the adults and children for each room;
+// guests who do not fit are left out, so check the rooms with Fits first.
+func Split(rooms []models.Room, adults, children int) ([]int, []int) {
+	roomAdults := make([]int, len(rooms))
+	roomChildren := make([]int, len(rooms))
+
+	free := make([]int, len(rooms))
+	for i, room := range rooms {
+		free[i] = room.Capacity
+		if adults > 0 && free[i] > 0 {
+			roomAdults[i]++
+			free[i]--
+			adults--
+		}
+	}
+
+	for i := range rooms {
+		for free[i] > 0 && adults > 0 {
+			roomAdults[i]++
+			free[i]--
+			adults--
+		}
+		for free[i] > 0 && children > 0 {
+			roomChildren[i]++
+			free[i]--
+			children--
+		}
+	}
+
+	return roomAdults, roomChildren
+}
@@ -0,0 +1,89 @@
+package party
+
+import (
+	"learn-golang/internal/models"
+	"testing"
+)
+
+var rooms = []models.Room{
+	{ID: 1, RoomName: "Suite", Capacity: 4},
+	{ID: 2, RoomName: "Double", Capacity: 2},
+	{ID: 3, RoomName: "Twin", Capacity: 2},
+	{ID: 4, RoomName: "Single", Capacity: 1},
+}
+
+func roomIDs(o Option) []int {
+	var ids []int
+	for _, room := range o.Rooms {
+		ids = append(ids, room.ID)
+	}
+	return ids
+}
+
+func TestOptions(t *testing.T) {
+	options := Options(rooms, 2, 0)
+	if len(options) != 3 || !options[0].Single() || options[0].Capacity != 2 || options[2].Rooms[0].ID != 1 {
+		t.Errorf("expected the three rooms for two, tightest first, got %v", options)
+	}
+	for _, o := range options {
+		if !o.Single() {
+			t.Errorf("expected no combinations when single rooms fit two guests who could share, got %v", roomIDs(o))
+		}
+	}
+
+	options = Options(rooms, 2, 3)
+	if len(options) == 0 || options[0].Single() {
+		t.Fatalf("expected only combinations for five guests, got %v", options)
+	}
+	if options[0].Capacity != 5 {
+		t.Errorf("expected the tightest combination first, got %v with capacity %d", roomIDs(options[0]), options[0].Capacity)
+	}
+	for _, o := range options {
+		if len(o.Rooms) > 2 {
+			t.Errorf("expected at most one room per adult, got %v", roomIDs(o))
+		}
+		if o.Capacity-o.Rooms[0].Capacity >= 5 {
+			t.Errorf("expected no room the party could do without, got %v", roomIDs(o))
+		}
+	}
+
+	if options := Options(rooms, 3, 7); len(options) != 0 {
+		t.Errorf("expected nothing to fit ten guests, got %v", options)
+	}
+	if options := Options(rooms, 0, 0); len(options) != 0 {
+		t.Errorf("expected no options for an empty party, got %v", options)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	adults, children := Split([]models.Room{rooms[1], rooms[0]}, 2, 3)
+
+	if adults[0] != 1 || adults[1] != 1 {
+		t.Errorf("expected an adult in each room, got %v", adults)
+	}
+	if children[0] != 1 || children[1] != 2 {
+		t.Errorf("expected children to fill the rooms in order, got %v", children)
+	}
+}
+
+func TestFits(t *testing.T) {
+	tests := []struct {
+		name     string
+		rooms    []models.Room
+		adults   int
+		children int
+		expected bool
+	}{
+		{"exactly", []models.Room{rooms[1]}, 1, 1, true},
+		{"too many guests", []models.Room{rooms[1]}, 2, 1, false},
+		{"two rooms", []models.Room{rooms[1], rooms[3]}, 2, 1, true},
+		{"room without an adult", []models.Room{rooms[1], rooms[3]}, 1, 2, false},
+		{"no rooms", nil, 1, 0, false},
+	}
+
+	for _, e := range tests {
+		if got := Fits(e.rooms, e.adults, e.children); got != e.expected {
+			t.Errorf("%s: expected %v, got %v", e.name, e.expected, got)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package pricing
+
+import (
+	"learn-golang/internal/models"
+	"time"
+)
+
+// IsWeekendNight returns true for Friday and Saturday nights, which carry the room's weekend surcharge
+func IsWeekendNight(d time.Time) bool {
+	return d.Weekday() == time.Friday || d.Weekday() == time.Saturday
+}
+
+// Quote prices each night of a stay in a room, from start up to but not including end, and returns
+// the nightly breakdown with its total. A night inside a seasonal rate uses that rate instead of the
+// room's nightly rate; when seasons overlap, the one starting latest wins.
+func Quote(room models.Room, seasons []models.SeasonalRate, start, end time.Time) ([]models.NightPrice, int) {
+	var nights []models.NightPrice
+	total := 0
+
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		night := models.NightPrice{
+			Date: d,
+			Rate: room.NightlyRate,
+		}
+
+		var season *models.SeasonalRate
+		for i := range seasons {
+			s := &seasons[i]
+			if s.RoomID != room.ID || d.Before(s.StartDate) || d.After(s.EndDate) {
+				continue
+			}
+			if season == nil || s.StartDate.After(season.StartDate) {
+				season = s
+			}
+		}
+		if season != nil {
+			night.Rate = season.NightlyRate
+			night.Season = season.Name
+		}
+
+		if IsWeekendNight(d) {
+			night.Surcharge = room.WeekendSurcharge
+		}
+
+		nights = append(nights, night)
+		total += night.Total()
+	}
+
+	return nights, total
+}
@@ -0,0 +1,57 @@
+package pricing
+
+import (
+	"learn-golang/internal/models"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	d, _ := time.Parse("2006-01-02", s)
+	return d
+}
+
+func TestQuote(t *testing.T) {
+	room := models.Room{ID: 1, NightlyRate: 10000, WeekendSurcharge: 2500}
+
+	// Wednesday to Sunday: Wed, Thu, Fri (weekend), Sat (weekend)
+	nights, total := Quote(room, nil, date("2050-06-01"), date("2050-06-05"))
+	if len(nights) != 4 {
+		t.Fatalf("expected 4 nights, got %d", len(nights))
+	}
+	if total != 4*10000+2*2500 {
+		t.Errorf("expected total %d, got %d", 4*10000+2*2500, total)
+	}
+	if nights[2].Surcharge != 2500 || nights[1].Surcharge != 0 {
+		t.Error("weekend surcharge should apply to Friday and Saturday nights only")
+	}
+
+	seasons := []models.SeasonalRate{
+		{RoomID: 1, Name: "Summer", StartDate: date("2050-06-01"), EndDate: date("2050-08-31"), NightlyRate: 15000},
+		{RoomID: 1, Name: "Festival", StartDate: date("2050-06-02"), EndDate: date("2050-06-02"), NightlyRate: 20000},
+		{RoomID: 2, Name: "Other room", StartDate: date("2050-01-01"), EndDate: date("2050-12-31"), NightlyRate: 1},
+	}
+
+	nights, total = Quote(room, seasons, date("2050-05-31"), date("2050-06-03"))
+	expected := []struct {
+		rate   int
+		season string
+	}{
+		{10000, ""},
+		{15000, "Summer"},
+		{20000, "Festival"},
+	}
+	for i, e := range expected {
+		if nights[i].Rate != e.rate || nights[i].Season != e.season {
+			t.Errorf("night %d: got %d (%q), want %d (%q)", i, nights[i].Rate, nights[i].Season, e.rate, e.season)
+		}
+	}
+	if total != 45000 {
+		t.Errorf("expected total 45000, got %d", total)
+	}
+
+	nights, total = Quote(room, seasons, date("2050-06-01"), date("2050-06-01"))
+	if len(nights) != 0 || total != 0 {
+		t.Error("an empty stay should cost nothing")
+	}
+}
@@ -0,0 +1,110 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"learn-golang/internal/i18n"
+	"learn-golang/internal/models"
+	"path/filepath"
+	"strings"
+	ttemplate "text/template"
+)
+
+var pathToEmailTemplates = "./email-templates"
+
+// Email renders the email template tmpl, e.g. "confirmation", in the locale l into a message with a subject, an
+// html part and a plain text part; the caller fills in who it is from and to. Emails are rendered from the
+// caches built at startup when they are queued; the outbox later sends the rendered message as it was stored.
+func Email(tmpl string, l *i18n.Locale, ed *models.EmailData) (models.MailData, error) {
+	var msg models.MailData
+
+	ht, ok := app.EmailCache[tmpl]
+	if !ok {
+		return msg, errors.New("can't get email template from cache")
+	}
+	tt, ok := app.EmailTextCache[tmpl]
+	if !ok {
+		return msg, errors.New("can't get email template from cache")
+	}
+
+	ht, err := ht.Clone()
+	if err != nil {
+		return msg, err
+	}
+	ht.Funcs(template.FuncMap(l.Funcs()))
+
+	tt, err = tt.Clone()
+	if err != nil {
+		return msg, err
+	}
+	tt.Funcs(ttemplate.FuncMap(l.Funcs()))
+
+	buf := new(bytes.Buffer)
+	err = tt.ExecuteTemplate(buf, "subject", ed)
+	if err != nil {
+		return msg, err
+	}
+	msg.Subject = strings.Join(strings.Fields(buf.String()), " ")
+
+	buf.Reset()
+	err = tt.Execute(buf, ed)
+	if err != nil {
+		return msg, err
+	}
+	msg.Text = strings.TrimSpace(buf.String()) + "\n"
+
+	buf.Reset()
+	err = ht.Execute(buf, ed)
+	if err != nil {
+		return msg, err
+	}
+	msg.Content = buf.String()
+
+	return msg, nil
+}
+
+// CreateEmailTemplateCache parses every email, keyed by name, into an html and a plain text template cache.
+// An email named confirmation is made of confirmation.page.html.tmpl and confirmation.page.txt.tmpl, each
+// with the layouts of the same kind. Finding no emails at all is an error, as nothing could be sent.
+func CreateEmailTemplateCache() (map[string]*template.Template, map[string]*ttemplate.Template, error) {
+	htmlCache := map[string]*template.Template{}
+	textCache := map[string]*ttemplate.Template{}
+
+	pages, err := filepath.Glob(fmt.Sprintf("%s/*.page.html.tmpl", pathToEmailTemplates))
+	if err != nil {
+		return htmlCache, textCache, err
+	}
+	if len(pages) == 0 {
+		return htmlCache, textCache, fmt.Errorf("no email templates in %s", pathToEmailTemplates)
+	}
+
+	for _, page := range pages {
+		name := strings.TrimSuffix(filepath.Base(page), ".page.html.tmpl")
+
+		ht, err := template.New(filepath.Base(page)).Funcs(functions).ParseFiles(page)
+		if err != nil {
+			return htmlCache, textCache, err
+		}
+		ht, err = ht.ParseGlob(fmt.Sprintf("%s/*.layout.html.tmpl", pathToEmailTemplates))
+		if err != nil {
+			return htmlCache, textCache, err
+		}
+
+		textPage := fmt.Sprintf("%s/%s.page.txt.tmpl", pathToEmailTemplates, name)
+		tt, err := ttemplate.New(filepath.Base(textPage)).Funcs(ttemplate.FuncMap(functions)).ParseFiles(textPage)
+		if err != nil {
+			return htmlCache, textCache, err
+		}
+		tt, err = tt.ParseGlob(fmt.Sprintf("%s/*.layout.txt.tmpl", pathToEmailTemplates))
+		if err != nil {
+			return htmlCache, textCache, err
+		}
+
+		htmlCache[name] = ht
+		textCache[name] = tt
+	}
+
+	return htmlCache, textCache, nil
+}
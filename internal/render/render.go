@@ -7,13 +7,24 @@ import (
 	"github.com/justinas/nosurf"
 	"html/template"
 	"learn-golang/internal/config"
+	"learn-golang/internal/i18n"
 	"learn-golang/internal/models"
 	"log"
 	"net/http"
 	"path/filepath"
+	"time"
 )
 
-var functions = template.FuncMap{}
+// functions are the template functions; t, date and dateTime are replaced by those of the request's locale
+// when a template is rendered
+var functions = template.FuncMap{
+	"humanDate":      HumanDate,
+	"formatCurrency": FormatCurrency,
+	"lang":           func() string { return i18n.Default.Tag },
+	"t":              i18n.Default.T,
+	"date":           i18n.Default.FormatDate,
+	"dateTime":       i18n.Default.FormatDateTime,
+}
 
 var app *config.AppConfig
 var pathToTemplates = "./templates"
@@ -23,12 +34,30 @@ func NewRenderer(a *config.AppConfig) {
 	app = a
 }
 
+// HumanDate returns time in YYYY-MM-DD format
+func HumanDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// FormatCurrency formats an amount in cents as dollars, e.g. 12900 as $129.00
+func FormatCurrency(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s$%d.%02d", sign, cents/100, cents%100)
+}
+
 // AddDefaultData adds data for all templates
 func AddDefaultData(td *models.TemplateData, r *http.Request) *models.TemplateData {
 	td.Flash = app.Session.PopString(r.Context(), "flash")
 	td.Error = app.Session.PopString(r.Context(), "error")
 	td.Warning = app.Session.PopString(r.Context(), "warning")
 	td.CSRFToken = nosurf.Token(r)
+	td.Locale = i18n.FromContext(r.Context()).Tag
+	td.Locales = i18n.Supported
+	td.CurrentPath = r.URL.RequestURI()
 	if app.Session.Exists(r.Context(), "user_id") {
 		td.IsAuthenticated = 1
 	}
@@ -53,11 +82,18 @@ func Template(w http.ResponseWriter, r *http.Request, tmpl string, td *models.Te
 		return errors.New("can't get template from cache")
 	}
 
+	// the cached template is shared by every request, so the locale's functions go on a copy of it
+	t, err := t.Clone()
+	if err != nil {
+		return err
+	}
+	t.Funcs(template.FuncMap(i18n.FromContext(r.Context()).Funcs()))
+
 	buf := new(bytes.Buffer)
 
 	td = AddDefaultData(td, r)
 
-	err := t.Execute(buf, td)
+	err = t.Execute(buf, td)
 	if err != nil {
 		log.Fatal(err)
 	}
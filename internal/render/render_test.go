@@ -1,9 +1,12 @@
 package render
 
 import (
+	"learn-golang/internal/i18n"
 	"learn-golang/internal/models"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestAddDefaultData(t *testing.T) {
@@ -61,6 +64,22 @@ func TestCreateTemplateCache(t *testing.T) {
 	}
 }
 
+func TestFormatCurrency(t *testing.T) {
+	tests := map[int]string{
+		0:      "$0.00",
+		5:      "$0.05",
+		12900:  "$129.00",
+		123456: "$1234.56",
+		-250:   "-$2.50",
+	}
+
+	for cents, expected := range tests {
+		if got := FormatCurrency(cents); got != expected {
+			t.Errorf("FormatCurrency(%d) = %s, want %s", cents, got, expected)
+		}
+	}
+}
+
 func getSession() (*http.Request, error) {
 	r, err := http.NewRequest("GET", "/some-url", nil)
 	if err != nil {
@@ -73,3 +92,106 @@ func getSession() (*http.Request, error) {
 
 	return r, nil
 }
+
+func TestEmail(t *testing.T) {
+	pathToEmailTemplates = "./../../email-templates"
+	htmlCache, textCache, err := CreateEmailTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.EmailCache = htmlCache
+	app.EmailTextCache = textCache
+
+	// emails render from the cache even while pages are re-parsed at every request
+	app.UseCache = false
+	pathToEmailTemplates = "./no-such-directory"
+	defer func() {
+		pathToEmailTemplates = "./../../email-templates"
+	}()
+
+	res := models.Reservation{
+		FirstName:        "<script>alert('hi')</script>",
+		ConfirmationCode: "ABCD2345",
+		StartDate:        time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:          time.Date(2050, 1, 3, 0, 0, 0, 0, time.UTC),
+	}
+	ed := &models.EmailData{
+		Reservation: res,
+		Room:        models.Room{RoomName: "General's Quarters"},
+		TotalPrice:  28900,
+		ManageLink:  "http://localhost:8080/manage/token",
+	}
+
+	msg, err := Email("confirmation", i18n.Default, ed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Subject != "Reservation Confirmation" {
+		t.Errorf("expected subject Reservation Confirmation, got %q", msg.Subject)
+	}
+	if strings.Contains(msg.Content, "<script>") || !strings.Contains(msg.Content, "&lt;script&gt;") {
+		t.Error("expected the guest's name to be escaped in the html part")
+	}
+	if !strings.Contains(msg.Content, `href="http://localhost:8080/manage/token"`) {
+		t.Error("expected the html part to link to the manage page")
+	}
+	for _, want := range []string{"January 1, 2050", "$289.00", "ABCD2345", "http://localhost:8080/manage/token"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("expected the text part to contain %q, got:\n%s", want, msg.Text)
+		}
+	}
+	if strings.Contains(msg.Text, "&lt;") || strings.Contains(msg.Text, "<strong>") {
+		t.Errorf("expected the text part to hold no html, got:\n%s", msg.Text)
+	}
+
+	fr, _ := i18n.Lookup("fr")
+	msg, err = Email("confirmation", fr, ed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Confirmation de réservation" {
+		t.Errorf("expected subject Confirmation de réservation, got %q", msg.Subject)
+	}
+	for _, want := range []string{"1 janvier 2050", `lang="fr"`} {
+		if !strings.Contains(msg.Text+msg.Content, want) {
+			t.Errorf("expected the French email to contain %q", want)
+		}
+	}
+
+	_, err = Email("non-existent", i18n.Default, ed)
+	if err == nil {
+		t.Error("rendered email template that does not exist")
+	}
+}
+
+func TestCreateEmailTemplateCache(t *testing.T) {
+	pathToEmailTemplates = "./../../email-templates"
+	htmlCache, textCache, err := CreateEmailTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every email needs both parts
+	for name := range htmlCache {
+		if textCache[name] == nil {
+			t.Errorf("email %s has no plain text template", name)
+		}
+	}
+	if len(htmlCache) == 0 {
+		t.Error("expected some email templates")
+	}
+}
+
+func TestCreateEmailTemplateCache_NoTemplates(t *testing.T) {
+	pathToEmailTemplates = "./no-such-directory"
+	defer func() {
+		pathToEmailTemplates = "./../../email-templates"
+	}()
+
+	_, _, err := CreateEmailTemplateCache()
+	if err == nil {
+		t.Error("expected an error when there are no email templates")
+	}
+}
@@ -2,9 +2,18 @@ package dbrepo
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
 	"golang.org/x/crypto/bcrypt"
+	"learn-golang/internal/bookingrules"
+	"learn-golang/internal/helpers"
 	"learn-golang/internal/models"
+	"learn-golang/internal/repository"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -12,90 +21,243 @@ func (*postgresDBRepo) AllUsers() bool {
 	return true
 }
 
-// InsertReservation inserts a reservation into the database
-func (rp *postgresDBRepo) InsertReservation(m models.Reservation) (int, error) {
+// InsertReservationWithRestriction re-checks availability and inserts a reservation, its room restriction and
+// any mail about it in a single transaction, returning repository.ErrRoomNotAvailable if the room was taken
+// in the meantime
+func (rp *postgresDBRepo) InsertReservationWithRestriction(m models.Reservation, mail ...models.MailData) (int, error) {
+	ids, err := rp.InsertReservationsWithRestrictions([]models.Reservation{m}, mail...)
+	if err != nil {
+		return 0, err
+	}
+
+	return ids[0], nil
+}
+
+// InsertReservationsWithRestrictions books several rooms together: it re-checks availability and inserts each
+// reservation and its room restriction in a single transaction, so either every room is booked or none is.
+// Mail about the booking is queued in the same transaction. It returns the new IDs in the order given,
+// repository.ErrRoomNotAvailable if any room was taken, or a *bookingrules.Violation if a room's booking rules
+// do not accept its stay.
+func (rp *postgresDBRepo) InsertReservationsWithRestrictions(ms []models.Reservation, mail ...models.MailData) ([]int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	var newId int
-	stmt := `
-        INSERT INTO reservations 
-            (first_name, last_name, email, phone, start_date, end_date, room_id, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id
+	tx, err := rp.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// lock rooms in id order so two bookings sharing rooms cannot deadlock
+	order := make([]int, len(ms))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return ms[order[i]].RoomID < ms[order[j]].RoomID
+	})
+
+	ids := make([]int, len(ms))
+	for _, i := range order {
+		ids[i], err = insertReservationTx(ctx, tx, ms[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = insertMailTx(ctx, tx, mail)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, overlapError(err)
+	}
+
+	return ids, nil
+}
+
+// lockRoomTx locks the room of m so concurrent bookings for it queue up behind tx, and checks the room is
+// still bookable and its booking rules accept the stay, returning a *bookingrules.Violation if they do not
+func lockRoomTx(ctx context.Context, tx *sql.Tx, m models.Reservation) error {
+	var retired bool
+	var rules models.BookingRules
+
+	query := `
+        SELECT r.retired, ` + bookingRulesColumns + `
+        FROM rooms r
+            LEFT JOIN booking_rules br ON br.room_id = r.id
+        WHERE r.id = $1
+        FOR UPDATE OF r
     `
+	err := tx.QueryRowContext(ctx, query, m.RoomID).Scan(append([]any{&retired}, bookingRulesDest(&rules)...)...)
+	if err != nil {
+		return err
+	}
+	if retired {
+		return repository.ErrRoomNotAvailable
+	}
 
-	err := rp.DB.QueryRowContext(
-		ctx, stmt,
-		m.FirstName, m.LastName, m.Email, m.Phone, m.StartDate, m.EndDate, m.RoomID,
-		time.Now(), time.Now(),
-	).Scan(&newId)
+	return bookingrules.Check(rules, m.StartDate, m.EndDate, time.Now())
+}
 
+// insertReservationTx locks the room, re-checks it is free and inserts a reservation and its room restriction
+func insertReservationTx(ctx context.Context, tx *sql.Tx, m models.Reservation) (int, error) {
+	err := lockRoomTx(ctx, tx, m)
 	if err != nil {
 		return 0, err
 	}
 
-	return newId, nil
-}
+	var numRows int
+	query := `
+        SELECT COUNT(id)
+        FROM room_restrictions
+        WHERE room_id = $1 AND $2 < end_date AND $3 > start_date
+    `
+	err = tx.QueryRowContext(ctx, query, m.RoomID, m.StartDate, m.EndDate).Scan(&numRows)
+	if err != nil {
+		return 0, err
+	}
+	if numRows > 0 {
+		return 0, repository.ErrRoomNotAvailable
+	}
 
-// InsertRoomRestriction inserts a new room restriction into the database
-func (rp *postgresDBRepo) InsertRoomRestriction(m models.RoomRestriction) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	code, err := confirmationCode(m)
+	if err != nil {
+		return 0, err
+	}
 
+	var newID int
 	stmt := `
-        INSERT INTO room_restrictions 
+        INSERT INTO reservations
+            (first_name, last_name, email, phone, start_date, end_date, room_id, total_price, confirmation_code,
+             adults, children, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) returning id
+    `
+	err = tx.QueryRowContext(
+		ctx, stmt,
+		m.FirstName, m.LastName, m.Email, m.Phone, m.StartDate, m.EndDate, m.RoomID, m.TotalPrice, code,
+		m.Adults, m.Children, time.Now(), time.Now(),
+	).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt = `
+        INSERT INTO room_restrictions
             (start_date, end_date, room_id, reservation_id, created_at, updated_at, restriction_id)
         VALUES ($1, $2, $3, $4, $5, $6, $7)
     `
-
-	_, err := rp.DB.ExecContext(
+	_, err = tx.ExecContext(
 		ctx, stmt,
-		m.StartDate, m.EndDate, m.RoomID, m.ReservationID, time.Now(), time.Now(), m.RestrictionID,
+		m.StartDate, m.EndDate, m.RoomID, newID, time.Now(), time.Now(), models.RestrictionReservation,
 	)
-
 	if err != nil {
-		return err
+		return 0, overlapError(err)
+	}
+
+	return newID, nil
+}
+
+// insertMailTx queues mail in the outbox as part of tx, so it is only sent if tx commits
+func insertMailTx(ctx context.Context, tx *sql.Tx, mail []models.MailData) error {
+	stmt := `
+        INSERT INTO outbox_messages
+            (to_address, from_address, subject, content, text_content, attachments, status, next_attempt_at,
+             created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, $8)
+    `
+
+	for _, m := range mail {
+		attachments, err := json.Marshal(m.Attachments)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx, stmt, m.To, m.From, m.Subject, m.Content, m.Text, string(attachments), models.OutboxPending, time.Now(),
+		)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// overlapError turns a violation of the room_restrictions_no_overlap constraint into repository.ErrRoomNotAvailable
+func overlapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23P01" {
+		return repository.ErrRoomNotAvailable
+	}
+	return err
+}
+
+// confirmationCode returns the confirmation code of a reservation about to be inserted, generating one if it has none
+func confirmationCode(m models.Reservation) (string, error) {
+	if m.ConfirmationCode != "" {
+		return m.ConfirmationCode, nil
+	}
+	return helpers.NewConfirmationCode()
+}
+
 // SearchAvailabilityByDatesByRoomID returns true if availability exists for roomID, and false otherwise
 func (rp *postgresDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error) {
+	return rp.SearchAvailabilityByDatesByRoomIDExcluding(start, end, roomID, 0)
+}
+
+// SearchAvailabilityByDatesByRoomIDExcluding returns true if availability exists for roomID, ignoring the
+// restriction held by reservationID so a reservation can be checked against its own new dates. A room whose
+// booking rules do not accept the stay is not available.
+func (rp *postgresDBRepo) SearchAvailabilityByDatesByRoomIDExcluding(start, end time.Time, roomID, reservationID int) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	var numRows int
+	var available bool
+	var rules models.BookingRules
 
 	query := `
-        SELECT COUNT(id) 
-        FROM room_restrictions 
-        WHERE 
-            room_id = $1 AND
-            $2 < end_date AND $3 > start_date
+        SELECT NOT r.retired AND NOT EXISTS
+            (SELECT 1 FROM room_restrictions rr
+             WHERE rr.room_id = r.id AND $2 < rr.end_date AND $3 > rr.start_date
+               AND rr.reservation_id IS DISTINCT FROM $4),
+            ` + bookingRulesColumns + `
+        FROM rooms r
+            LEFT JOIN booking_rules br ON br.room_id = r.id
+        WHERE r.id = $1
     `
 
 	row := rp.DB.QueryRowContext(
 		ctx, query,
-		roomID, start, end,
+		roomID, start, end, reservationID,
 	)
-	err := row.Scan(&numRows)
+	err := row.Scan(append([]any{&available}, bookingRulesDest(&rules)...)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
 
-	return numRows == 0, nil
+	return available && bookingrules.Check(rules, start, end, time.Now()) == nil, nil
 }
 
-// SearchAvailabilityForAllRooms returns a slice of available rooms, if any, for given date range
+// SearchAvailabilityForAllRooms returns a slice of available rooms, if any, for given date range,
+// leaving out rooms whose booking rules do not accept the stay
 func (rp *postgresDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) (rooms []models.Room, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	query := `
-        SELECT r.id, r.room_name
+        SELECT r.id, r.room_name, r.capacity, ` + bookingRulesColumns + `
         FROM rooms r 
+            LEFT JOIN booking_rules br ON br.room_id = r.id
         WHERE 
+            NOT r.retired AND
             r.id NOT IN
                 (SELECT room_id from room_restrictions rr WHERE $1 < rr.end_date AND $2 > rr.start_date)
     `
@@ -108,13 +270,18 @@ func (rp *postgresDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) (r
 		return
 	}
 
+	now := time.Now()
 	for rows.Next() {
 		var room models.Room
-		err = rows.Scan(&room.ID, &room.RoomName)
+		var rules models.BookingRules
+		err = rows.Scan(append([]any{&room.ID, &room.RoomName, &room.Capacity}, bookingRulesDest(&rules)...)...)
 		if err != nil {
 			return
 		}
 
+		if bookingrules.Check(rules, start, end, now) != nil {
+			continue
+		}
 		rooms = append(rooms, room)
 	}
 
@@ -125,6 +292,15 @@ func (rp *postgresDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) (r
 	return
 }
 
+// bookingRulesColumns selects the booking rules joined as br, as zero values for a room without rules
+const bookingRulesColumns = `COALESCE(br.min_nights, 0), COALESCE(br.max_nights, 0), COALESCE(br.weekend_min_nights, 0),
+            COALESCE(br.no_arrival_days, 0), COALESCE(br.lead_time_hours, 0)`
+
+// bookingRulesDest returns the scan destinations for bookingRulesColumns
+func bookingRulesDest(b *models.BookingRules) []any {
+	return []any{&b.MinNights, &b.MaxNights, &b.WeekendMinNights, &b.NoArrivalDays, &b.LeadTimeHours}
+}
+
 // GetRoomById gets a room by id
 func (rp *postgresDBRepo) GetRoomById(id int) (models.Room, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -132,21 +308,92 @@ func (rp *postgresDBRepo) GetRoomById(id int) (models.Room, error) {
 
 	var room models.Room
 	query := `
-        SELECT r.id, r.room_name, r.created_at, r.updated_at
+        SELECT r.id, r.room_name, r.slug, r.description, r.capacity, r.nightly_rate, r.weekend_surcharge, r.retired,
+               r.created_at, r.updated_at
         FROM rooms r
         WHERE r.id = $1
     `
 
 	row := rp.DB.QueryRowContext(ctx, query, id)
-	err := row.Scan(&room.ID, &room.RoomName, &room.CreatedAt, &room.UpdatedAt)
+	err := row.Scan(
+		&room.ID, &room.RoomName, &room.Slug, &room.Description, &room.Capacity, &room.NightlyRate, &room.WeekendSurcharge, &room.Retired,
+		&room.CreatedAt, &room.UpdatedAt,
+	)
+
+	if err != nil {
+		return room, err
+	}
+
+	return room, nil
+}
 
+// GetRoomBySlug gets a room, with its photos, by its URL slug
+func (rp *postgresDBRepo) GetRoomBySlug(slug string) (models.Room, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var room models.Room
+	query := `
+        SELECT r.id, r.room_name, r.slug, r.description, r.capacity, r.nightly_rate, r.weekend_surcharge, r.retired,
+               r.created_at, r.updated_at
+        FROM rooms r
+        WHERE r.slug = $1
+    `
+
+	row := rp.DB.QueryRowContext(ctx, query, slug)
+	err := row.Scan(
+		&room.ID, &room.RoomName, &room.Slug, &room.Description, &room.Capacity, &room.NightlyRate, &room.WeekendSurcharge, &room.Retired,
+		&room.CreatedAt, &room.UpdatedAt,
+	)
+	if err != nil {
+		return room, err
+	}
+
+	photos, err := rp.roomPhotos(ctx, room.ID)
 	if err != nil {
 		return room, err
 	}
+	room.Photos = photos[room.ID]
 
 	return room, nil
 }
 
+// roomPhotos returns photos in display order grouped by room ID, for one room or, when roomID is 0, for all rooms
+func (rp *postgresDBRepo) roomPhotos(ctx context.Context, roomID int) (map[int][]models.RoomPhoto, error) {
+	photos := make(map[int][]models.RoomPhoto)
+
+	query := `
+        SELECT id, room_id, path, caption, sort_order, created_at, updated_at
+        FROM room_photos
+        WHERE $1 = 0 OR room_id = $1
+        ORDER BY room_id, sort_order, id
+    `
+
+	rows, err := rp.DB.QueryContext(ctx, query, roomID)
+	if err != nil {
+		return photos, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var p models.RoomPhoto
+		err = rows.Scan(&p.ID, &p.RoomID, &p.Path, &p.Caption, &p.SortOrder, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return photos, err
+		}
+
+		photos[p.RoomID] = append(photos[p.RoomID], p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return photos, err
+	}
+
+	return photos, nil
+}
+
 // GetUserById returns a user by ID
 func (rp *postgresDBRepo) GetUserById(id int) (models.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -215,3 +462,1058 @@ func (rp *postgresDBRepo) Authenticate(email, testPassword string) (int, string,
 
 	return id, hashedPassword, nil
 }
+
+// AllNewReservations returns a slice of reservations not yet processed, newest first, joined with their room
+func (rp *postgresDBRepo) AllNewReservations() ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	query := `
+        SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date,
+               r.room_id, r.created_at, r.updated_at, r.processed, r.confirmation_code, r.cancelled_at,
+               rm.id, rm.room_name
+        FROM reservations r
+        LEFT JOIN rooms rm ON (r.room_id = rm.id)
+        WHERE r.processed = 0
+        ORDER BY r.created_at DESC
+    `
+
+	rows, err := rp.DB.QueryContext(ctx, query)
+	if err != nil {
+		return reservations, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var i models.Reservation
+		var cancelledAt sql.NullTime
+		err = rows.Scan(
+			&i.ID, &i.FirstName, &i.LastName, &i.Email, &i.Phone, &i.StartDate, &i.EndDate,
+			&i.RoomID, &i.CreatedAt, &i.UpdatedAt, &i.Processed, &i.ConfirmationCode, &cancelledAt,
+			&i.Room.ID, &i.Room.RoomName,
+		)
+		if err != nil {
+			return reservations, err
+		}
+		i.CancelledAt = cancelledAt.Time
+
+		reservations = append(reservations, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// reservationSortColumns maps the sort keys accepted by AllReservations to their columns
+var reservationSortColumns = map[string]string{
+	"start_date": "r.start_date",
+	"last_name":  "r.last_name",
+	"room":       "rm.room_name",
+	"created_at": "r.created_at",
+}
+
+// AllReservations returns one page of reservations matching the filter, and the total number of matches
+func (rp *postgresDBRepo) AllReservations(f models.ReservationFilter) ([]models.Reservation, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+	var total int
+
+	var where []string
+	var args []any
+
+	if !f.From.IsZero() {
+		args = append(args, f.From)
+		where = append(where, fmt.Sprintf("r.end_date >= $%d", len(args)))
+	}
+	if !f.To.IsZero() {
+		args = append(args, f.To)
+		where = append(where, fmt.Sprintf("r.start_date <= $%d", len(args)))
+	}
+	if f.RoomID > 0 {
+		args = append(args, f.RoomID)
+		where = append(where, fmt.Sprintf("r.room_id = $%d", len(args)))
+	}
+
+	whereClause := ""
+	if len(where) > 0 {
+		whereClause = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	err := rp.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM reservations r"+whereClause, args...).Scan(&total)
+	if err != nil {
+		return reservations, total, err
+	}
+
+	query := `
+        SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date,
+               r.room_id, r.created_at, r.updated_at, r.processed, r.confirmation_code, r.cancelled_at,
+               rm.id, rm.room_name
+        FROM reservations r
+        LEFT JOIN rooms rm ON (r.room_id = rm.id)
+    ` + whereClause
+
+	column, ok := reservationSortColumns[f.SortBy]
+	if !ok {
+		column = reservationSortColumns["start_date"]
+	}
+	direction := "ASC"
+	if f.SortDesc {
+		direction = "DESC"
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, r.id %s", column, direction, direction)
+
+	if f.PerPage > 0 {
+		page := f.Page
+		if page < 1 {
+			page = 1
+		}
+		args = append(args, f.PerPage, (page-1)*f.PerPage)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	rows, err := rp.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return reservations, total, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var i models.Reservation
+		var cancelledAt sql.NullTime
+		err = rows.Scan(
+			&i.ID, &i.FirstName, &i.LastName, &i.Email, &i.Phone, &i.StartDate, &i.EndDate,
+			&i.RoomID, &i.CreatedAt, &i.UpdatedAt, &i.Processed, &i.ConfirmationCode, &cancelledAt,
+			&i.Room.ID, &i.Room.RoomName,
+		)
+		if err != nil {
+			return reservations, total, err
+		}
+		i.CancelledAt = cancelledAt.Time
+
+		reservations = append(reservations, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, total, err
+	}
+
+	return reservations, total, nil
+}
+
+// AllRooms returns all rooms, including retired ones, with their photos
+func (rp *postgresDBRepo) AllRooms() ([]models.Room, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var rooms []models.Room
+
+	query := `
+        SELECT id, room_name, slug, description, capacity, nightly_rate, weekend_surcharge, retired,
+               created_at, updated_at
+        FROM rooms
+        ORDER BY room_name
+    `
+
+	rows, err := rp.DB.QueryContext(ctx, query)
+	if err != nil {
+		return rooms, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var room models.Room
+		err = rows.Scan(
+			&room.ID, &room.RoomName, &room.Slug, &room.Description, &room.Capacity, &room.NightlyRate, &room.WeekendSurcharge, &room.Retired,
+			&room.CreatedAt, &room.UpdatedAt,
+		)
+		if err != nil {
+			return rooms, err
+		}
+
+		rooms = append(rooms, room)
+	}
+
+	if err = rows.Err(); err != nil {
+		return rooms, err
+	}
+
+	photos, err := rp.roomPhotos(ctx, 0)
+	if err != nil {
+		return rooms, err
+	}
+	for i := range rooms {
+		rooms[i].Photos = photos[rooms[i].ID]
+	}
+
+	return rooms, nil
+}
+
+// GetRestrictionsForRoomByDate returns the restrictions for a room that overlap the given date range
+func (rp *postgresDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var restrictions []models.RoomRestriction
+
+	query := `
+        SELECT id, COALESCE(reservation_id, 0), restriction_id, room_id, start_date, end_date
+        FROM room_restrictions
+        WHERE $1 < end_date AND $2 >= start_date AND room_id = $3
+        ORDER BY start_date
+    `
+
+	rows, err := rp.DB.QueryContext(ctx, query, start, end, roomID)
+	if err != nil {
+		return restrictions, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var rr models.RoomRestriction
+		err = rows.Scan(&rr.ID, &rr.ReservationID, &rr.RestrictionID, &rr.RoomID, &rr.StartDate, &rr.EndDate)
+		if err != nil {
+			return restrictions, err
+		}
+
+		restrictions = append(restrictions, rr)
+	}
+
+	if err = rows.Err(); err != nil {
+		return restrictions, err
+	}
+
+	return restrictions, nil
+}
+
+// GetAvailabilityForRoomByDate returns whether a room is free for each night from start up to but not
+// including end, or nothing if there is no such room
+func (rp *postgresDBRepo) GetAvailabilityForRoomByDate(roomID int, start, end time.Time) ([]models.DayAvailability, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var days []models.DayAvailability
+
+	query := `
+        SELECT d::date, NOT r.retired AND NOT EXISTS
+            (SELECT 1 FROM room_restrictions rr
+             WHERE rr.room_id = r.id AND rr.start_date <= d::date AND rr.end_date > d::date)
+        FROM rooms r
+            CROSS JOIN generate_series($2::date, $3::date - 1, interval '1 day') d
+        WHERE r.id = $1
+        ORDER BY d
+    `
+
+	rows, err := rp.DB.QueryContext(ctx, query, roomID, start, end)
+	if err != nil {
+		return days, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var day models.DayAvailability
+		err = rows.Scan(&day.Date, &day.Available)
+		if err != nil {
+			return days, err
+		}
+
+		days = append(days, day)
+	}
+
+	if err = rows.Err(); err != nil {
+		return days, err
+	}
+
+	return days, nil
+}
+
+// InsertBlockForRoom inserts an owner block for a single night of a room
+func (rp *postgresDBRepo) InsertBlockForRoom(roomID int, startDate time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `
+        INSERT INTO room_restrictions
+            (start_date, end_date, room_id, reservation_id, restriction_id, created_at, updated_at)
+        VALUES ($1, $2, $3, NULL, $4, $5, $6)
+    `
+
+	_, err := rp.DB.ExecContext(
+		ctx, stmt,
+		startDate, startDate.AddDate(0, 0, 1), roomID, models.RestrictionOwnerBlock, time.Now(), time.Now(),
+	)
+	if err != nil {
+		return overlapError(err)
+	}
+
+	return nil
+}
+
+// DeleteBlockByID deletes an owner block
+func (rp *postgresDBRepo) DeleteBlockByID(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `DELETE FROM room_restrictions WHERE id = $1 AND restriction_id = $2 AND reservation_id IS NULL`
+
+	_, err := rp.DB.ExecContext(ctx, stmt, id, models.RestrictionOwnerBlock)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReplaceBlock deletes an owner block and blocks the given nights of its room instead, in one transaction, so
+// a failure part way leaves the original block in place
+func (rp *postgresDBRepo) ReplaceBlock(id, roomID int, nights []time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := rp.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(
+		ctx, `DELETE FROM room_restrictions WHERE id = $1 AND restriction_id = $2 AND reservation_id IS NULL`,
+		id, models.RestrictionOwnerBlock,
+	)
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+        INSERT INTO room_restrictions
+            (start_date, end_date, room_id, reservation_id, restriction_id, created_at, updated_at)
+        VALUES ($1, $2, $3, NULL, $4, $5, $5)
+    `
+	for _, night := range nights {
+		_, err = tx.ExecContext(
+			ctx, stmt, night, night.AddDate(0, 0, 1), roomID, models.RestrictionOwnerBlock, time.Now(),
+		)
+		if err != nil {
+			return overlapError(err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetReservationByID returns one reservation by ID, joined with its room
+func (rp *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error) {
+	return rp.getReservation("r.id = $1", id)
+}
+
+// GetReservationByCode returns the reservation with the given confirmation code, joined with its room
+func (rp *postgresDBRepo) GetReservationByCode(code string) (models.Reservation, error) {
+	return rp.getReservation("r.confirmation_code = $1", strings.ToUpper(strings.TrimSpace(code)))
+}
+
+// getReservation returns the single reservation matching the where clause, joined with its room
+func (rp *postgresDBRepo) getReservation(where string, arg any) (models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var res models.Reservation
+	var cancelledAt sql.NullTime
+
+	query := `
+        SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date,
+               r.room_id, r.created_at, r.updated_at, r.processed, r.total_price, r.confirmation_code,
+               r.cancelled_at, r.adults, r.children, rm.id, rm.room_name
+        FROM reservations r
+        LEFT JOIN rooms rm ON (r.room_id = rm.id)
+        WHERE ` + where
+
+	row := rp.DB.QueryRowContext(ctx, query, arg)
+	err := row.Scan(
+		&res.ID, &res.FirstName, &res.LastName, &res.Email, &res.Phone, &res.StartDate, &res.EndDate,
+		&res.RoomID, &res.CreatedAt, &res.UpdatedAt, &res.Processed, &res.TotalPrice, &res.ConfirmationCode,
+		&cancelledAt, &res.Adults, &res.Children, &res.Room.ID, &res.Room.RoomName,
+	)
+	if err != nil {
+		return res, err
+	}
+	res.CancelledAt = cancelledAt.Time
+
+	return res, nil
+}
+
+// UpdateReservation updates the guest details of a reservation; its room and dates are moved with
+// ModifyReservation, which checks availability
+func (rp *postgresDBRepo) UpdateReservation(m models.Reservation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `
+        UPDATE reservations
+        SET first_name = $1, last_name = $2, email = $3, phone = $4, updated_at = $5
+        WHERE id = $6
+    `
+
+	_, err := rp.DB.ExecContext(ctx, stmt, m.FirstName, m.LastName, m.Email, m.Phone, time.Now(), m.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteReservation deletes a reservation and the room restriction that holds its room
+func (rp *postgresDBRepo) DeleteReservation(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := rp.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM room_restrictions WHERE reservation_id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM reservations WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CancelReservation frees the room held by a reservation, records when it was cancelled and queues any mail
+// about it, returning repository.ErrReservationCancelled if it already was
+func (rp *postgresDBRepo) CancelReservation(id int, mail ...models.MailData) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := rp.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	stmt := "UPDATE reservations SET cancelled_at = $1, updated_at = $1 WHERE id = $2 AND cancelled_at IS NULL"
+
+	result, err := tx.ExecContext(ctx, stmt, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repository.ErrReservationCancelled
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM room_restrictions WHERE reservation_id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	err = insertMailTx(ctx, tx, mail)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ModifyReservation moves a reservation to new dates or another room, re-checking availability
+// without its own restriction and the room's booking rules, and updating the reservation, its restriction and
+// queueing any mail together
+func (rp *postgresDBRepo) ModifyReservation(m models.Reservation, mail ...models.MailData) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := rp.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = lockRoomTx(ctx, tx, m)
+	if err != nil {
+		return err
+	}
+
+	var numRows int
+	query := `
+        SELECT COUNT(id)
+        FROM room_restrictions
+        WHERE room_id = $1 AND $2 < end_date AND $3 > start_date AND reservation_id IS DISTINCT FROM $4
+    `
+	err = tx.QueryRowContext(ctx, query, m.RoomID, m.StartDate, m.EndDate, m.ID).Scan(&numRows)
+	if err != nil {
+		return err
+	}
+	if numRows > 0 {
+		return repository.ErrRoomNotAvailable
+	}
+
+	// a cancelled reservation no longer holds a room, so moving it would give it dates without blocking any
+	stmt := `
+        UPDATE reservations
+        SET start_date = $1, end_date = $2, room_id = $3, total_price = $4, updated_at = $5
+        WHERE id = $6 AND cancelled_at IS NULL
+    `
+	result, err := tx.ExecContext(ctx, stmt, m.StartDate, m.EndDate, m.RoomID, m.TotalPrice, time.Now(), m.ID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return repository.ErrReservationCancelled
+	}
+
+	stmt = `
+        UPDATE room_restrictions
+        SET start_date = $1, end_date = $2, room_id = $3, updated_at = $4
+        WHERE reservation_id = $5
+    `
+	result, err = tx.ExecContext(ctx, stmt, m.StartDate, m.EndDate, m.RoomID, time.Now(), m.ID)
+	if err != nil {
+		return overlapError(err)
+	}
+	n, err = result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return fmt.Errorf("reservation %d holds %d room restrictions, expected 1", m.ID, n)
+	}
+
+	err = insertMailTx(ctx, tx, mail)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateProcessedForReservation sets the processed flag of a reservation
+func (rp *postgresDBRepo) UpdateProcessedForReservation(id, processed int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := "UPDATE reservations SET processed = $1, updated_at = $2 WHERE id = $3"
+
+	_, err := rp.DB.ExecContext(ctx, stmt, processed, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// InsertRoom inserts a new room and returns its ID
+func (rp *postgresDBRepo) InsertRoom(room models.Room) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+	stmt := `
+        INSERT INTO rooms
+            (room_name, slug, description, capacity, nightly_rate, weekend_surcharge, retired, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id
+    `
+
+	err := rp.DB.QueryRowContext(
+		ctx, stmt,
+		room.RoomName, room.Slug, room.Description, room.Capacity, room.NightlyRate, room.WeekendSurcharge,
+		room.Retired, time.Now(), time.Now(),
+	).Scan(&newID)
+	if err != nil {
+		return 0, slugError(err)
+	}
+
+	return newID, nil
+}
+
+// UpdateRoom updates a room
+func (rp *postgresDBRepo) UpdateRoom(room models.Room) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `
+        UPDATE rooms
+        SET room_name = $1, slug = $2, description = $3, capacity = $4, nightly_rate = $5,
+            weekend_surcharge = $6, retired = $7, updated_at = $8
+        WHERE id = $9
+    `
+
+	_, err := rp.DB.ExecContext(
+		ctx, stmt,
+		room.RoomName, room.Slug, room.Description, room.Capacity, room.NightlyRate, room.WeekendSurcharge,
+		room.Retired, time.Now(), room.ID,
+	)
+	if err != nil {
+		return slugError(err)
+	}
+
+	return nil
+}
+
+// DeleteRoom retires a room: it can no longer be booked, but it and its reservations are kept
+func (rp *postgresDBRepo) DeleteRoom(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := "UPDATE rooms SET retired = true, updated_at = $1 WHERE id = $2"
+
+	_, err := rp.DB.ExecContext(ctx, stmt, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// slugError turns a violation of the unique rooms_slug_idx index into repository.ErrSlugTaken
+func slugError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "rooms_slug_idx" {
+		return repository.ErrSlugTaken
+	}
+	return err
+}
+
+// GetSeasonalRatesForRoom returns the seasonal rates of a room, earliest first
+func (rp *postgresDBRepo) GetSeasonalRatesForRoom(roomID int) ([]models.SeasonalRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var seasons []models.SeasonalRate
+
+	query := `
+        SELECT id, room_id, name, start_date, end_date, nightly_rate, created_at, updated_at
+        FROM seasonal_rates
+        WHERE room_id = $1
+        ORDER BY start_date, id
+    `
+
+	rows, err := rp.DB.QueryContext(ctx, query, roomID)
+	if err != nil {
+		return seasons, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var s models.SeasonalRate
+		err = rows.Scan(&s.ID, &s.RoomID, &s.Name, &s.StartDate, &s.EndDate, &s.NightlyRate, &s.CreatedAt, &s.UpdatedAt)
+		if err != nil {
+			return seasons, err
+		}
+
+		seasons = append(seasons, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return seasons, err
+	}
+
+	return seasons, nil
+}
+
+// InsertSeasonalRate inserts a seasonal rate for a room
+func (rp *postgresDBRepo) InsertSeasonalRate(s models.SeasonalRate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `
+        INSERT INTO seasonal_rates
+            (room_id, name, start_date, end_date, nightly_rate, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `
+
+	_, err := rp.DB.ExecContext(
+		ctx, stmt,
+		s.RoomID, s.Name, s.StartDate, s.EndDate, s.NightlyRate, time.Now(), time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteSeasonalRate deletes a seasonal rate
+func (rp *postgresDBRepo) DeleteSeasonalRate(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := rp.DB.ExecContext(ctx, "DELETE FROM seasonal_rates WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetBookingRulesForRoom returns the booking rules of a room, or empty rules if it has none
+func (rp *postgresDBRepo) GetBookingRulesForRoom(roomID int) (models.BookingRules, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rules := models.BookingRules{RoomID: roomID}
+
+	query := `
+        SELECT id, min_nights, max_nights, weekend_min_nights, no_arrival_days, lead_time_hours, created_at, updated_at
+        FROM booking_rules
+        WHERE room_id = $1
+    `
+
+	row := rp.DB.QueryRowContext(ctx, query, roomID)
+	err := row.Scan(
+		&rules.ID, &rules.MinNights, &rules.MaxNights, &rules.WeekendMinNights, &rules.NoArrivalDays,
+		&rules.LeadTimeHours, &rules.CreatedAt, &rules.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rules, nil
+	}
+	if err != nil {
+		return rules, err
+	}
+
+	return rules, nil
+}
+
+// UpdateBookingRules saves the booking rules of a room, replacing any it had
+func (rp *postgresDBRepo) UpdateBookingRules(b models.BookingRules) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `
+        INSERT INTO booking_rules
+            (room_id, min_nights, max_nights, weekend_min_nights, no_arrival_days, lead_time_hours, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+        ON CONFLICT (room_id) DO UPDATE SET
+            min_nights = EXCLUDED.min_nights, max_nights = EXCLUDED.max_nights,
+            weekend_min_nights = EXCLUDED.weekend_min_nights, no_arrival_days = EXCLUDED.no_arrival_days,
+            lead_time_hours = EXCLUDED.lead_time_hours, updated_at = EXCLUDED.updated_at
+    `
+
+	_, err := rp.DB.ExecContext(
+		ctx, stmt,
+		b.RoomID, b.MinNights, b.MaxNights, b.WeekendMinNights, b.NoArrivalDays, b.LeadTimeHours, time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// apiKeyColumns are the api_keys columns read by scanAPIKey, in order
+const apiKeyColumns = "id, name, prefix, key_hash, scopes, last_used_at, revoked_at, created_at, updated_at"
+
+// scanAPIKey scans one row of apiKeyColumns into an API key
+func scanAPIKey(row interface{ Scan(...any) error }) (models.APIKey, error) {
+	var k models.APIKey
+	var scopes string
+	var lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(
+		&k.ID, &k.Name, &k.Prefix, &k.KeyHash, &scopes, &lastUsedAt, &revokedAt, &k.CreatedAt, &k.UpdatedAt,
+	)
+	if err != nil {
+		return k, err
+	}
+
+	if scopes != "" {
+		k.Scopes = strings.Split(scopes, ",")
+	}
+	k.LastUsedAt = lastUsedAt.Time
+	k.RevokedAt = revokedAt.Time
+
+	return k, nil
+}
+
+// AllAPIKeys returns every API key, including revoked ones, newest first
+func (rp *postgresDBRepo) AllAPIKeys() ([]models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var keys []models.APIKey
+
+	rows, err := rp.DB.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY created_at DESC")
+	if err != nil {
+		return keys, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return keys, err
+		}
+		keys = append(keys, k)
+	}
+
+	if err = rows.Err(); err != nil {
+		return keys, err
+	}
+
+	return keys, nil
+}
+
+// InsertAPIKey stores a new API key and returns its ID
+func (rp *postgresDBRepo) InsertAPIKey(k models.APIKey) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+	stmt := `
+        INSERT INTO api_keys (name, prefix, key_hash, scopes, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6) returning id
+    `
+
+	err := rp.DB.QueryRowContext(
+		ctx, stmt,
+		k.Name, k.Prefix, k.KeyHash, strings.Join(k.Scopes, ","), time.Now(), time.Now(),
+	).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// RevokeAPIKey revokes an API key; revoked keys are kept so the admin tool can still show them
+func (rp *postgresDBRepo) RevokeAPIKey(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := "UPDATE api_keys SET revoked_at = $1, updated_at = $1 WHERE id = $2 AND revoked_at IS NULL"
+
+	_, err := rp.DB.ExecContext(ctx, stmt, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetAPIKeyByHash returns the API key with the given hash, revoked or not
+func (rp *postgresDBRepo) GetAPIKeyByHash(hash string) (models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row := rp.DB.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1", hash)
+	return scanAPIKey(row)
+}
+
+// UpdateAPIKeyLastUsed records that an API key was just used
+func (rp *postgresDBRepo) UpdateAPIKeyLastUsed(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := rp.DB.ExecContext(ctx, "UPDATE api_keys SET last_used_at = $1 WHERE id = $2", time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// outboxColumns are the outbox_messages columns read by scanOutboxMessage, in order
+const outboxColumns = `id, to_address, from_address, subject, content, text_content, attachments, status, attempts,
+    next_attempt_at, last_error, sent_at, created_at, updated_at`
+
+// scanOutboxMessage scans one row of outboxColumns into an outbox message
+func scanOutboxMessage(row interface{ Scan(...any) error }) (models.OutboxMessage, error) {
+	var m models.OutboxMessage
+	var attachments string
+	var sentAt sql.NullTime
+
+	err := row.Scan(
+		&m.ID, &m.Mail.To, &m.Mail.From, &m.Mail.Subject, &m.Mail.Content, &m.Mail.Text, &attachments, &m.Status,
+		&m.Attempts, &m.NextAttemptAt, &m.LastError, &sentAt, &m.CreatedAt, &m.UpdatedAt,
+	)
+	if err != nil {
+		return m, err
+	}
+	m.SentAt = sentAt.Time
+
+	err = json.Unmarshal([]byte(attachments), &m.Mail.Attachments)
+	if err != nil {
+		return m, err
+	}
+
+	return m, nil
+}
+
+// ClaimDueMail returns up to limit pending messages that are due to be sent, pushing their next attempt back
+// by lease so no other worker picks them up while they are being sent. A message whose sender dies without
+// recording the outcome becomes due again once the lease runs out.
+func (rp *postgresDBRepo) ClaimDueMail(limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var messages []models.OutboxMessage
+
+	query := `
+        UPDATE outbox_messages
+        SET next_attempt_at = $1
+        WHERE id IN (
+            SELECT id
+            FROM outbox_messages
+            WHERE status = $2 AND next_attempt_at <= $3
+            ORDER BY next_attempt_at
+            LIMIT $4
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING ` + outboxColumns
+
+	now := time.Now()
+	rows, err := rp.DB.QueryContext(ctx, query, now.Add(lease), models.OutboxPending, now, limit)
+	if err != nil {
+		return messages, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		m, err := scanOutboxMessage(rows)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return messages, err
+	}
+
+	return messages, nil
+}
+
+// UpdateOutboxMessage records the outcome of an attempt to send an outbox message
+func (rp *postgresDBRepo) UpdateOutboxMessage(m models.OutboxMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var sentAt sql.NullTime
+	if !m.SentAt.IsZero() {
+		sentAt = sql.NullTime{Time: m.SentAt, Valid: true}
+	}
+
+	stmt := `
+        UPDATE outbox_messages
+        SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, sent_at = $5, updated_at = $6
+        WHERE id = $7
+    `
+
+	_, err := rp.DB.ExecContext(
+		ctx, stmt, m.Status, m.Attempts, m.NextAttemptAt, m.LastError, sentAt, time.Now(), m.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AllOutboxMessages returns the outbox messages with the given status, or every message when status is empty,
+// newest first
+func (rp *postgresDBRepo) AllOutboxMessages(status string) ([]models.OutboxMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var messages []models.OutboxMessage
+
+	query := `
+        SELECT ` + outboxColumns + `
+        FROM outbox_messages
+        WHERE $1 = '' OR status = $1
+        ORDER BY created_at DESC, id DESC
+        LIMIT 200
+    `
+
+	rows, err := rp.DB.QueryContext(ctx, query, status)
+	if err != nil {
+		return messages, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		m, err := scanOutboxMessage(rows)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return messages, err
+	}
+
+	return messages, nil
+}
+
+// GetOutboxMessageByID returns an outbox message by id
+func (rp *postgresDBRepo) GetOutboxMessageByID(id int) (models.OutboxMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row := rp.DB.QueryRowContext(ctx, "SELECT "+outboxColumns+" FROM outbox_messages WHERE id = $1", id)
+	return scanOutboxMessage(row)
+}
+
+// ResendOutboxMessage puts an outbox message back in the queue to be sent straight away, with a fresh set of attempts
+func (rp *postgresDBRepo) ResendOutboxMessage(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `
+        UPDATE outbox_messages
+        SET status = $1, attempts = 0, next_attempt_at = $2, updated_at = $2
+        WHERE id = $3
+    `
+
+	_, err := rp.DB.ExecContext(ctx, stmt, models.OutboxPending, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
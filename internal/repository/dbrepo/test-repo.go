@@ -1,38 +1,143 @@
 package dbrepo
 
 import (
+	"database/sql"
 	"errors"
+	"learn-golang/internal/apikey"
+	"learn-golang/internal/bookingrules"
 	"learn-golang/internal/models"
+	"learn-golang/internal/repository"
+	"sort"
+	"strings"
 	"time"
 )
 
+// testRooms are the rooms the testing repository knows about
+var testRooms = []models.Room{
+	{
+		ID:               1,
+		RoomName:         "General's Quarters",
+		Slug:             "generals-quarters",
+		Description:      "A cosy room",
+		Capacity:         2,
+		NightlyRate:      8900,
+		WeekendSurcharge: 1000,
+		Photos:           []models.RoomPhoto{{ID: 1, RoomID: 1, Path: "/static/images/generals-quarters.png"}},
+	},
+	{
+		ID:          2,
+		RoomName:    "Major's Suite",
+		Slug:        "majors-suite",
+		Description: "Our largest suite",
+		Capacity:    4,
+		NightlyRate: 12900,
+		Photos: []models.RoomPhoto{
+			{ID: 2, RoomID: 2, Path: "/static/images/majors-suite.png"},
+			{ID: 3, RoomID: 2, Path: "/static/images/outside.png"},
+		},
+	},
+	{
+		ID:          4,
+		RoomName:    "Old Wing",
+		Slug:        "old-wing",
+		Description: "Closed for good",
+		Capacity:    2,
+		NightlyRate: 7900,
+		Retired:     true,
+	},
+}
+
 func (*testDBRepo) AllUsers() bool {
 	return true
 }
 
-// InsertReservation inserts a reservation into the database
-func (rp *testDBRepo) InsertReservation(_ models.Reservation) (int, error) {
-	return 1, nil
+// InsertReservationWithRestriction re-checks availability and inserts a reservation, its room restriction and
+// any mail about it in a single transaction, returning repository.ErrRoomNotAvailable if the room was taken
+// in the meantime
+func (rp *testDBRepo) InsertReservationWithRestriction(m models.Reservation, mail ...models.MailData) (int, error) {
+	ids, err := rp.InsertReservationsWithRestrictions([]models.Reservation{m}, mail...)
+	if err != nil {
+		return 0, err
+	}
+
+	return ids[0], nil
 }
 
-// InsertRoomRestriction inserts a new room restriction into the database
-func (rp *testDBRepo) InsertRoomRestriction(_ models.RoomRestriction) error {
-	return nil
+// InsertReservationsWithRestrictions books several rooms together: it re-checks availability and inserts each
+// reservation and its room restriction in a single transaction, so either every room is booked or none is.
+// Mail about the booking is queued in the same transaction. It returns the new IDs in the order given, or
+// repository.ErrRoomNotAvailable if any room was taken.
+func (rp *testDBRepo) InsertReservationsWithRestrictions(ms []models.Reservation, mail ...models.MailData) ([]int, error) {
+	var ids []int
+	for i, m := range ms {
+		if m.StartDate.Year() == 2053 {
+			return nil, testRuleViolation
+		}
+		if m.RoomID == 2 {
+			return nil, repository.ErrRoomNotAvailable
+		}
+		if m.RoomID > 2 {
+			return nil, errors.New("some error")
+		}
+		ids = append(ids, i+1)
+	}
+
+	rp.sendMail(mail)
+	return ids, nil
+}
+
+// testRuleViolation is what booking or moving a stay in 2053 returns, as if the room's booking rules had
+// changed since the stay was checked
+var testRuleViolation = &bookingrules.Violation{Message: "Stays in this room must be at least %d nights", Args: []any{3}}
+
+// sendMail stands in for the outbox: the testing repository hands mail it is asked to queue straight to
+// the app's mailer, so handler tests can check what would be sent
+func (rp *testDBRepo) sendMail(mail []models.MailData) {
+	if rp.App.Mailer == nil {
+		return
+	}
+	for _, m := range mail {
+		_ = rp.App.Mailer.Send(m)
+	}
 }
 
 // SearchAvailabilityByDatesByRoomID returns true if availability exists for roomID, and false otherwise
-func (rp *testDBRepo) SearchAvailabilityByDatesByRoomID(_, _ time.Time, _ int) (bool, error) {
-	return false, nil
+func (rp *testDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error) {
+	return rp.SearchAvailabilityByDatesByRoomIDExcluding(start, end, roomID, 0)
+}
+
+// SearchAvailabilityByDatesByRoomIDExcluding returns true if availability exists for roomID, ignoring the
+// restriction held by reservationID
+func (rp *testDBRepo) SearchAvailabilityByDatesByRoomIDExcluding(_, _ time.Time, roomID, _ int) (bool, error) {
+	return roomID == 1, nil
 }
 
 // SearchAvailabilityForAllRooms returns a slice of available rooms, if any, for given date range
-func (rp *testDBRepo) SearchAvailabilityForAllRooms(_, _ time.Time) (rooms []models.Room, err error) {
-	return
+func (rp *testDBRepo) SearchAvailabilityForAllRooms(start, _ time.Time) (rooms []models.Room, err error) {
+	if start.Year() >= 2051 {
+		return
+	}
+	for _, r := range testRooms {
+		if !r.Retired {
+			rooms = append(rooms, r)
+		}
+	}
+	return rooms, nil
 }
 
 // GetRoomById gets a room by id
 func (rp *testDBRepo) GetRoomById(id int) (models.Room, error) {
 	var room models.Room
+	for _, r := range testRooms {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+
+	// rooms from 9 on do not exist, and looking up room 3 fails
+	if id >= 9 {
+		return room, sql.ErrNoRows
+	}
 	if id > 2 {
 		return room, errors.New("some error")
 	}
@@ -40,6 +145,17 @@ func (rp *testDBRepo) GetRoomById(id int) (models.Room, error) {
 	return room, nil
 }
 
+// GetRoomBySlug gets a room, with its photos, by its URL slug
+func (rp *testDBRepo) GetRoomBySlug(slug string) (models.Room, error) {
+	for _, r := range testRooms {
+		if r.Slug == slug {
+			return r, nil
+		}
+	}
+
+	return models.Room{}, sql.ErrNoRows
+}
+
 func (rp *testDBRepo) GetUserById(_ int) (models.User, error) {
 	var u models.User
 	return u, nil
@@ -52,3 +168,331 @@ func (rp *testDBRepo) UpdateUser(_ models.User) error {
 func (rp *testDBRepo) Authenticate(_, _ string) (int, string, error) {
 	return 0, "", nil
 }
+
+// AllNewReservations returns a slice of reservations not yet processed, newest first, joined with their room
+func (rp *testDBRepo) AllNewReservations() ([]models.Reservation, error) {
+	var reservations []models.Reservation
+	return reservations, nil
+}
+
+// AllReservations returns one page of reservations matching the filter, and the total number of matches
+func (rp *testDBRepo) AllReservations(_ models.ReservationFilter) ([]models.Reservation, int, error) {
+	var reservations []models.Reservation
+	return reservations, 0, nil
+}
+
+// AllRooms returns all rooms, including retired ones, with their photos
+func (rp *testDBRepo) AllRooms() ([]models.Room, error) {
+	return testRooms, nil
+}
+
+// GetRestrictionsForRoomByDate returns the restrictions for a room that overlap the given date range
+func (rp *testDBRepo) GetRestrictionsForRoomByDate(roomID int, start, _ time.Time) ([]models.RoomRestriction, error) {
+	restrictions := []models.RoomRestriction{
+		{
+			ID:            1,
+			StartDate:     start.AddDate(0, 0, 1),
+			EndDate:       start.AddDate(0, 0, 3),
+			RoomID:        roomID,
+			ReservationID: 1,
+			RestrictionID: 1,
+		},
+		{
+			ID:            2,
+			StartDate:     start.AddDate(0, 0, 5),
+			EndDate:       start.AddDate(0, 0, 6),
+			RoomID:        roomID,
+			RestrictionID: 2,
+		},
+	}
+	return restrictions, nil
+}
+
+// GetAvailabilityForRoomByDate returns whether a room is free for each night from start up to but not
+// including end, or nothing if there is no such room
+func (rp *testDBRepo) GetAvailabilityForRoomByDate(roomID int, start, end time.Time) ([]models.DayAvailability, error) {
+	var days []models.DayAvailability
+	if roomID > 2 {
+		return days, nil
+	}
+
+	// every room is taken from the 5th through the night of the 7th
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		days = append(days, models.DayAvailability{Date: d, Available: d.Day() < 5 || d.Day() > 7})
+	}
+	return days, nil
+}
+
+// InsertBlockForRoom inserts an owner block for a single night of a room
+func (rp *testDBRepo) InsertBlockForRoom(_ int, _ time.Time) error {
+	return nil
+}
+
+// ReplaceBlock deletes an owner block and blocks the given nights of its room instead
+func (rp *testDBRepo) ReplaceBlock(_, roomID int, _ []time.Time) error {
+	// the blocks of retired rooms are never changed
+	if roomID == 4 {
+		return errors.New("changed a block of a retired room")
+	}
+	return nil
+}
+
+// DeleteBlockByID deletes an owner block
+func (rp *testDBRepo) DeleteBlockByID(_ int) error {
+	return nil
+}
+
+// GetReservationByID returns one reservation by ID, joined with its room
+func (rp *testDBRepo) GetReservationByID(id int) (models.Reservation, error) {
+	var res models.Reservation
+	if id > 2 {
+		return res, errors.New("some error")
+	}
+
+	res = models.Reservation{
+		ID:        id,
+		FirstName: "John",
+		LastName:  "Smith",
+		Email:     "john@smith.com",
+		StartDate: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2050, 1, 3, 0, 0, 0, 0, time.UTC),
+		RoomID:    1,
+		Room:      models.Room{ID: 1, RoomName: "General's Quarters"},
+	}
+	res.ConfirmationCode = "ABCD2345"
+
+	return res, nil
+}
+
+// GetReservationByCode returns the reservation with the given confirmation code, joined with its room
+func (rp *testDBRepo) GetReservationByCode(code string) (models.Reservation, error) {
+	switch strings.ToUpper(code) {
+	case "ABCD2345":
+		return rp.GetReservationByID(1)
+	case "LATE2345":
+		// arrives tomorrow, inside any sensible cancellation window
+		res, _ := rp.GetReservationByID(2)
+		res.ConfirmationCode = "LATE2345"
+		res.StartDate = time.Now().AddDate(0, 0, 1)
+		res.EndDate = time.Now().AddDate(0, 0, 3)
+		return res, nil
+	case "PARTY234":
+		// a party of three, too many for room 1
+		res, _ := rp.GetReservationByID(2)
+		res.ConfirmationCode = "PARTY234"
+		res.Adults = 2
+		res.Children = 1
+		res.RoomID = 2
+		res.Room = models.Room{ID: 2, RoomName: "Major's Suite", Capacity: 4}
+		return res, nil
+	case "GONE2345":
+		res, _ := rp.GetReservationByID(2)
+		res.ID = 3
+		res.ConfirmationCode = "GONE2345"
+		res.CancelledAt = time.Date(2049, 12, 1, 0, 0, 0, 0, time.UTC)
+		return res, nil
+	}
+	return models.Reservation{}, sql.ErrNoRows
+}
+
+// ModifyReservation moves a reservation to new dates or another room
+func (rp *testDBRepo) ModifyReservation(m models.Reservation, mail ...models.MailData) error {
+	// stays in 2052 belong to a reservation cancelled between the lookup and the update
+	if m.StartDate.Year() == 2052 {
+		return repository.ErrReservationCancelled
+	}
+	if m.StartDate.Year() == 2053 {
+		return testRuleViolation
+	}
+	// stays from 2051 on are taken by someone else between the availability check and the update
+	if m.StartDate.Year() >= 2051 {
+		return repository.ErrRoomNotAvailable
+	}
+	rp.sendMail(mail)
+	return nil
+}
+
+// CancelReservation frees the room held by a reservation and records when it was cancelled
+func (rp *testDBRepo) CancelReservation(id int, mail ...models.MailData) error {
+	if id == 3 {
+		return repository.ErrReservationCancelled
+	}
+	rp.sendMail(mail)
+	return nil
+}
+
+// UpdateReservation updates the guest details of a reservation
+func (rp *testDBRepo) UpdateReservation(_ models.Reservation) error {
+	return nil
+}
+
+// DeleteReservation deletes a reservation and the room restriction that holds its room
+func (rp *testDBRepo) DeleteReservation(_ int) error {
+	return nil
+}
+
+// UpdateProcessedForReservation sets the processed flag of a reservation
+func (rp *testDBRepo) UpdateProcessedForReservation(_, _ int) error {
+	return nil
+}
+
+// InsertRoom inserts a new room and returns its ID
+func (rp *testDBRepo) InsertRoom(room models.Room) (int, error) {
+	if room.Slug == "majors-suite" {
+		return 0, repository.ErrSlugTaken
+	}
+	return 3, nil
+}
+
+// UpdateRoom updates a room
+func (rp *testDBRepo) UpdateRoom(room models.Room) error {
+	if room.ID != 2 && room.Slug == "majors-suite" {
+		return repository.ErrSlugTaken
+	}
+	return nil
+}
+
+// DeleteRoom retires a room: it can no longer be booked, but it and its reservations are kept
+func (rp *testDBRepo) DeleteRoom(_ int) error {
+	return nil
+}
+
+// GetSeasonalRatesForRoom returns the seasonal rates of a room, earliest first
+func (rp *testDBRepo) GetSeasonalRatesForRoom(roomID int) ([]models.SeasonalRate, error) {
+	seasons := []models.SeasonalRate{
+		{
+			ID:          1,
+			RoomID:      roomID,
+			Name:        "New Year",
+			StartDate:   time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:     time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+			NightlyRate: 20000,
+		},
+	}
+	return seasons, nil
+}
+
+// InsertSeasonalRate inserts a seasonal rate for a room
+func (rp *testDBRepo) InsertSeasonalRate(_ models.SeasonalRate) error {
+	return nil
+}
+
+// DeleteSeasonalRate deletes a seasonal rate
+func (rp *testDBRepo) DeleteSeasonalRate(_ int) error {
+	return nil
+}
+
+// GetBookingRulesForRoom returns the booking rules of a room, or empty rules if it has none
+func (rp *testDBRepo) GetBookingRulesForRoom(roomID int) (models.BookingRules, error) {
+	if roomID == 2 {
+		return models.BookingRules{ID: 1, RoomID: roomID, MinNights: 2, NoArrivalDays: 1 << time.Sunday}, nil
+	}
+	return models.BookingRules{RoomID: roomID}, nil
+}
+
+// UpdateBookingRules saves the booking rules of a room, replacing any it had
+func (rp *testDBRepo) UpdateBookingRules(_ models.BookingRules) error {
+	return nil
+}
+
+// testAPIKeys are the API keys the testing repository knows about, by key
+var testAPIKeys = map[string]models.APIKey{
+	"bk_test_read":    {ID: 1, Name: "Widget", Scopes: []string{models.ScopeReadAvailability}},
+	"bk_test_write":   {ID: 2, Name: "Partner", Scopes: []string{models.ScopeReadAvailability, models.ScopeWriteReservations}},
+	"bk_test_lookup":  {ID: 5, Name: "Concierge", Scopes: []string{models.ScopeReadReservations}},
+	"bk_test_admin":   {ID: 3, Name: "Back office", Scopes: []string{models.ScopeAdmin}},
+	"bk_test_revoked": {ID: 4, Name: "Old partner", Scopes: []string{models.ScopeAdmin}, RevokedAt: time.Date(2049, 1, 1, 0, 0, 0, 0, time.UTC)},
+}
+
+// AllAPIKeys returns every API key, including revoked ones, newest first
+func (rp *testDBRepo) AllAPIKeys() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	for key, k := range testAPIKeys {
+		k.Prefix = key[:7]
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+	return keys, nil
+}
+
+// InsertAPIKey stores a new API key and returns its ID
+func (rp *testDBRepo) InsertAPIKey(_ models.APIKey) (int, error) {
+	return 5, nil
+}
+
+// RevokeAPIKey revokes an API key
+func (rp *testDBRepo) RevokeAPIKey(_ int) error {
+	return nil
+}
+
+// GetAPIKeyByHash returns the API key with the given hash, revoked or not
+func (rp *testDBRepo) GetAPIKeyByHash(hash string) (models.APIKey, error) {
+	for key, k := range testAPIKeys {
+		if apikey.Hash(key) == hash {
+			k.KeyHash = hash
+			return k, nil
+		}
+	}
+	return models.APIKey{}, sql.ErrNoRows
+}
+
+// UpdateAPIKeyLastUsed records that an API key was just used
+func (rp *testDBRepo) UpdateAPIKeyLastUsed(_ int) error {
+	return nil
+}
+
+// testOutboxMessages are the outbox messages the testing repository knows about
+var testOutboxMessages = []models.OutboxMessage{
+	{
+		ID:        2,
+		Mail:      models.MailData{To: "john@smith.com", From: "me@here.com", Subject: "Reservation Confirmation", Content: "<strong>Hi</strong>"},
+		Status:    models.OutboxDead,
+		Attempts:  8,
+		LastError: "dial tcp 127.0.0.1:1025: connect: connection refused",
+		CreatedAt: time.Date(2049, 12, 1, 0, 0, 0, 0, time.UTC),
+	},
+	{
+		ID:        1,
+		Mail:      models.MailData{To: "me@here.com", From: "me@here.com", Subject: "Reservation Notification", Text: "A reservation has been made"},
+		Status:    models.OutboxSent,
+		Attempts:  1,
+		SentAt:    time.Date(2049, 12, 1, 0, 0, 1, 0, time.UTC),
+		CreatedAt: time.Date(2049, 12, 1, 0, 0, 0, 0, time.UTC),
+	},
+}
+
+// ClaimDueMail returns up to limit pending messages that are due to be sent
+func (rp *testDBRepo) ClaimDueMail(_ int, _ time.Duration) ([]models.OutboxMessage, error) {
+	return nil, nil
+}
+
+// UpdateOutboxMessage records the outcome of an attempt to send an outbox message
+func (rp *testDBRepo) UpdateOutboxMessage(_ models.OutboxMessage) error {
+	return nil
+}
+
+// AllOutboxMessages returns the outbox messages with the given status, or every message when status is empty
+func (rp *testDBRepo) AllOutboxMessages(status string) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	for _, m := range testOutboxMessages {
+		if status == "" || m.Status == status {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+// GetOutboxMessageByID returns an outbox message by id
+func (rp *testDBRepo) GetOutboxMessageByID(id int) (models.OutboxMessage, error) {
+	for _, m := range testOutboxMessages {
+		if m.ID == id {
+			return m, nil
+		}
+	}
+	return models.OutboxMessage{}, sql.ErrNoRows
+}
+
+// ResendOutboxMessage puts an outbox message back in the queue to be sent straight away
+func (rp *testDBRepo) ResendOutboxMessage(_ int) error {
+	return nil
+}
@@ -1,19 +1,65 @@
 package repository
 
 import (
+	"errors"
 	"learn-golang/internal/models"
 	"time"
 )
 
+// ErrRoomNotAvailable is returned when a room is already taken for some of the requested nights
+var ErrRoomNotAvailable = errors.New("room is no longer available for the selected dates")
+
+// ErrSlugTaken is returned when a room is saved with a slug another room already uses
+var ErrSlugTaken = errors.New("slug is already used by another room")
+
+// ErrReservationCancelled is returned when cancelling a reservation that has already been cancelled
+var ErrReservationCancelled = errors.New("reservation has already been cancelled")
+
 type DatabaseRepo interface {
 	AllUsers() bool
 
-	InsertReservation(models.Reservation) (int, error)
-	InsertRoomRestriction(models.RoomRestriction) error
+	InsertReservationWithRestriction(models.Reservation, ...models.MailData) (int, error)
+	InsertReservationsWithRestrictions([]models.Reservation, ...models.MailData) ([]int, error)
 	SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error)
+	SearchAvailabilityByDatesByRoomIDExcluding(start, end time.Time, roomID, reservationID int) (bool, error)
 	SearchAvailabilityForAllRooms(start, end time.Time) ([]models.Room, error)
 	GetRoomById(int) (models.Room, error)
+	GetRoomBySlug(string) (models.Room, error)
 	GetUserById(int) (models.User, error)
 	UpdateUser(models.User) error
 	Authenticate(string, string) (int, string, error)
+
+	AllNewReservations() ([]models.Reservation, error)
+	AllReservations(models.ReservationFilter) ([]models.Reservation, int, error)
+	AllRooms() ([]models.Room, error)
+	InsertRoom(models.Room) (int, error)
+	UpdateRoom(models.Room) error
+	DeleteRoom(id int) error
+	GetSeasonalRatesForRoom(roomID int) ([]models.SeasonalRate, error)
+	InsertSeasonalRate(models.SeasonalRate) error
+	DeleteSeasonalRate(id int) error
+	GetBookingRulesForRoom(roomID int) (models.BookingRules, error)
+	UpdateBookingRules(models.BookingRules) error
+	GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error)
+	GetAvailabilityForRoomByDate(roomID int, start, end time.Time) ([]models.DayAvailability, error)
+	InsertBlockForRoom(roomID int, startDate time.Time) error
+	DeleteBlockByID(id int) error
+	ReplaceBlock(id, roomID int, nights []time.Time) error
+	GetReservationByID(id int) (models.Reservation, error)
+	UpdateReservation(models.Reservation) error
+	DeleteReservation(id int) error
+	UpdateProcessedForReservation(id, processed int) error
+	GetReservationByCode(code string) (models.Reservation, error)
+	CancelReservation(id int, mail ...models.MailData) error
+	ModifyReservation(models.Reservation, ...models.MailData) error
+	AllAPIKeys() ([]models.APIKey, error)
+	InsertAPIKey(models.APIKey) (int, error)
+	RevokeAPIKey(id int) error
+	GetAPIKeyByHash(hash string) (models.APIKey, error)
+	UpdateAPIKeyLastUsed(id int) error
+	ClaimDueMail(limit int, lease time.Duration) ([]models.OutboxMessage, error)
+	UpdateOutboxMessage(models.OutboxMessage) error
+	AllOutboxMessages(status string) ([]models.OutboxMessage, error)
+	GetOutboxMessageByID(id int) (models.OutboxMessage, error)
+	ResendOutboxMessage(id int) error
 }
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS rooms_slug_idx;
+
+DELETE FROM room_photos;
+
+UPDATE rooms SET slug = '', description = '', capacity = 2, nightly_rate = 0;
@@ -0,0 +1,27 @@
+UPDATE rooms
+SET slug         = 'generals-quarters',
+    description  = 'Your home away from home, set on the majestic waters of the Atlantic Ocean, this will be a vacation to remember. A cosy room with a queen bed, a writing desk and a window looking out over the harbour.',
+    capacity     = 2,
+    nightly_rate = 8900
+WHERE room_name LIKE 'General%';
+
+UPDATE rooms
+SET slug         = 'majors-suite',
+    description  = 'Your home away from home, set on the majestic waters of the Atlantic Ocean, this will be a vacation to remember. Our largest suite, with a king bed, a sofa bed in the sitting room and a private balcony facing the ocean.',
+    capacity     = 4,
+    nightly_rate = 12900
+WHERE room_name LIKE 'Major%';
+
+INSERT INTO room_photos (room_id, path, caption, sort_order, created_at, updated_at)
+SELECT id, '/static/images/generals-quarters.png', room_name, 1, now(), now()
+FROM rooms
+WHERE slug = 'generals-quarters';
+
+INSERT INTO room_photos (room_id, path, caption, sort_order, created_at, updated_at)
+SELECT id, '/static/images/majors-suite.png', room_name, 1, now(), now()
+FROM rooms
+WHERE slug = 'majors-suite';
+
+UPDATE rooms SET slug = 'room-' || id WHERE slug = '';
+
+CREATE UNIQUE INDEX rooms_slug_idx ON rooms (slug);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS reservations_confirmation_code_idx;
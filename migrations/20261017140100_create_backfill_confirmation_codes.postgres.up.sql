@@ -0,0 +1,21 @@
+DO $$
+DECLARE
+    alphabet CONSTANT text := 'ABCDEFGHJKLMNPQRSTUVWXYZ23456789';
+    r record;
+    code text;
+BEGIN
+    FOR r IN SELECT id FROM reservations WHERE confirmation_code = '' LOOP
+        LOOP
+            code := '';
+            FOR i IN 1..8 LOOP
+                code := code || substr(alphabet, 1 + floor(random() * length(alphabet))::int, 1);
+            END LOOP;
+            EXIT WHEN NOT EXISTS (SELECT 1 FROM reservations WHERE confirmation_code = code);
+        END LOOP;
+
+        UPDATE reservations SET confirmation_code = code WHERE id = r.id;
+    END LOOP;
+END
+$$;
+
+CREATE UNIQUE INDEX reservations_confirmation_code_idx ON reservations (confirmation_code);
@@ -21,9 +21,10 @@ function checkAvailability(roomId, CSRFToken) {
         attention.custom({
             msg: html, title: 'Choose your dates', willOpen: () => {
                 const elem = document.getElementById("reservation-dates-modal");
-                new DateRangePicker(elem, {
+                const rangePicker = new DateRangePicker(elem, {
                     format: 'yyyy-mm-dd', showOnFocus: true, minDate: new Date()
                 })
+                roomCalendar(roomId, rangePicker);
             }, didOpen: () => {
                 document.getElementById("start").removeAttribute("disabled");
                 document.getElementById("end").removeAttribute("disabled");
@@ -58,4 +59,41 @@ function checkAvailability(roomId, CSRFToken) {
         }).then(() => {
         });
     });
-}
\ No newline at end of file
+}
+
+// roomCalendar loads the nights a room is taken, a month at a time as the pickers are paged, and disables
+// them: a taken night cannot be an arrival date, and the morning after it cannot be a departure date
+function roomCalendar(roomId, rangePicker) {
+    const loaded = {};
+    const arrivals = [];
+    const departures = [];
+
+    function load(date) {
+        const month = date.getFullYear() + '-' + String(date.getMonth() + 1).padStart(2, '0');
+        if (loaded[month]) {
+            return;
+        }
+        loaded[month] = true;
+
+        fetch(`/room-calendar-json?room_id=${roomId}&month=${month}`)
+            .then(response => response.json())
+            .then(data => {
+                if (!data.ok) {
+                    return;
+                }
+                data.days.filter(day => !day.available).forEach(day => {
+                    const night = new Date(day.date + 'T00:00:00');
+                    arrivals.push(new Date(night));
+                    night.setDate(night.getDate() + 1);
+                    departures.push(night);
+                })
+                rangePicker.datepickers[0].setOptions({datesDisabled: arrivals});
+                rangePicker.datepickers[1].setOptions({datesDisabled: departures});
+            })
+    }
+
+    rangePicker.inputs.forEach(input => {
+        input.addEventListener('changeMonth', e => load(e.detail.viewDate));
+    });
+    load(new Date());
+}